@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/knit/patcher"
+)
+
+func check(repo patcher.Repo, newRepo func(path string, messages patcher.CommitMessages) patcher.Repo, checkpoint patcher.Checkpoint) error {
+	err := buildInWorktree(repo, newRepo, checkpoint, "knit-check", nil)
+	if err != nil {
+		if stepErr, ok := err.(patcher.StepError); ok {
+			return fmt.Errorf("check failed at change %d of %d: %s: %s", stepErr.Change+1, len(checkpoint.Changes), stepErr.Step, stepErr.Err)
+		}
+
+		return fmt.Errorf("check failed: %s", err)
+	}
+
+	return nil
+}
+
+func buildInWorktree(repo patcher.Repo, newRepo func(path string, messages patcher.CommitMessages) patcher.Repo, checkpoint patcher.Checkpoint, name string, built func(worktree string, worktreeRepo patcher.Repo) error) error {
+	tmpDir, err := ioutil.TempDir("", name)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	checkpoint.CheckoutRef, err = repo.ResolveCommit(checkpoint.CheckoutRef)
+	if err != nil {
+		return err
+	}
+
+	worktree := filepath.Join(tmpDir, "worktree")
+	err = repo.AddWorktree(worktree, checkpoint.CheckoutRef)
+	if err != nil {
+		return err
+	}
+
+	checkpoint.FinalBranch = fmt.Sprintf("%s-%d/%s", name, os.Getpid(), checkpoint.FinalBranch)
+	defer repo.DeleteBranch(checkpoint.FinalBranch)
+	defer repo.RemoveWorktree(worktree)
+
+	worktreeRepo := newRepo(worktree, checkpoint.CommitMessages)
+	worktreeRepo.LocalForks = true
+
+	err = patcher.NewApply(&worktreeRepo, nil).Checkpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if built == nil {
+		return nil
+	}
+
+	return built(worktree, worktreeRepo)
+}
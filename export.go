@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/pivotal-cf/knit/patcher"
+)
+
+func export(args []string) {
+	var (
+		releaseRepository string
+		patchesRepository string
+		patchesRef        string
+		patchManifest     string
+		version           string
+		output            string
+		prefix            string
+		committerName     string
+		committerEmail    string
+		quiet             bool
+	)
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.StringVar(&releaseRepository, "repository-to-patch", "", "")
+	flags.StringVar(&patchesRepository, "patch-repository", "", "")
+	flags.StringVar(&patchesRef, "patch-repository-ref", "", "")
+	flags.StringVar(&patchManifest, "patch-manifest", "", "")
+	flags.StringVar(&version, "version", "", "")
+	flags.StringVar(&output, "output", "", "")
+	flags.StringVar(&prefix, "prefix", "", "")
+	flags.StringVar(&committerName, "committer-name", "", "")
+	flags.StringVar(&committerEmail, "committer-email", "", "")
+	flags.BoolVar(&quiet, "quiet", false, "")
+	flags.Parse(args)
+
+	var missingFlag string
+	switch {
+	case releaseRepository == "":
+		missingFlag = "repository-to-patch is a required flag"
+	case patchesRepository != "" && patchManifest != "":
+		missingFlag = "patch-repository and patch-manifest cannot be used together"
+	case patchesRef != "" && patchesRepository == "":
+		missingFlag = "patch-repository-ref requires patch-repository"
+	case patchesRepository == "" && patchManifest == "":
+		missingFlag = "patch-repository is a required flag"
+	case version == "":
+		missingFlag = "version is a required flag"
+	case output == "":
+		missingFlag = "output is a required flag"
+	}
+
+	if missingFlag != "" {
+		fatal(missingFlag)
+	}
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		fatal(err)
+	}
+
+	runner, err := patcher.NewCommandRunner(gitPath, quiet)
+	if err != nil {
+		fatal(err)
+	}
+
+	err = checkGitVersion(runner)
+	if err != nil {
+		fatal(err)
+	}
+
+	committerName, err = committerIdentity(runner, releaseRepository, committerName, "GIT_COMMITTER_NAME", "user.name")
+	if err != nil {
+		fatal(err)
+	}
+
+	committerEmail, err = committerIdentity(runner, releaseRepository, committerEmail, "GIT_COMMITTER_EMAIL", "user.email")
+	if err != nil {
+		fatal(err)
+	}
+
+	patchSet, _, err := newPatchSet(runner, patchesRepository, patchesRef, patchManifest, false)
+	if err != nil {
+		fatal(err)
+	}
+
+	checkpoint, err := patcher.NewVersionsParser(version, patchSet).GetCheckpoint()
+	if err != nil {
+		fatal(err)
+	}
+
+	newRepo := func(path string, messages patcher.CommitMessages) patcher.Repo {
+		repo := patcher.NewRepo(runner, path, committerName, committerEmail)
+		repo.CommitMessages = messages
+		return repo
+	}
+
+	repo := newRepo(releaseRepository, patcher.CommitMessages{})
+
+	modTime, err := repo.CommitTime(checkpoint.CheckoutRef)
+	if err != nil {
+		fatal(err)
+	}
+
+	err = buildInWorktree(repo, newRepo, checkpoint, "knit-export", func(worktree string, worktreeRepo patcher.Repo) error {
+		files, err := worktreeRepo.TrackedFiles()
+		if err != nil {
+			return err
+		}
+
+		archive, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		err = patcher.WriteArchive(archive, worktree, files, prefix, modTime)
+		if err != nil {
+			os.Remove(output)
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("exported %s to %s\n", checkpoint.FinalBranch, output)
+}
@@ -0,0 +1,129 @@
+package main_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var (
+		repoToPatch string
+		patchesDir  string
+		outputDir   string
+	)
+
+	BeforeEach(func() {
+		var err error
+		patchesDir, err = ioutil.TempDir("", "patch-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(patchesDir, "1.2"), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		repoToPatch, err = ioutil.TempDir("", "repo-to-patch")
+		Expect(err).NotTo(HaveOccurred())
+
+		outputDir, err = ioutil.TempDir("", "output")
+		Expect(err).NotTo(HaveOccurred())
+
+		initGitRepo(repoToPatch)
+
+		createPatch(repoToPatch, patchesDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(repoToPatch)
+		os.RemoveAll(patchesDir)
+		os.RemoveAll(outputDir)
+	})
+
+	export := func(output string) {
+		command := exec.Command(pathToKnit, "export",
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-version", "1.2.1",
+			"-prefix", "release-1.2.1",
+			"-output", output)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "10m").Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("exported 1.2.1 to " + output))
+	}
+
+	It("writes the patched tree as a tarball", func() {
+		output := filepath.Join(outputDir, "release.tgz")
+		export(output)
+
+		archive, err := os.Open(output)
+		Expect(err).NotTo(HaveOccurred())
+		defer archive.Close()
+
+		gzipReader, err := gzip.NewReader(archive)
+		Expect(err).NotTo(HaveOccurred())
+
+		files := map[string]string{}
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := ioutil.ReadAll(tarReader)
+			Expect(err).NotTo(HaveOccurred())
+
+			files[header.Name] = string(contents)
+		}
+
+		Expect(files).To(Equal(map[string]string{
+			"release-1.2.1/file-in-repo.txt": "hello, world!another change",
+		}))
+	})
+
+	It("produces identical bytes on every run without leaving a branch behind", func() {
+		first := filepath.Join(outputDir, "first.tgz")
+		second := filepath.Join(outputDir, "second.tgz")
+
+		export(first)
+		export(second)
+
+		firstBytes, err := ioutil.ReadFile(first)
+		Expect(err).NotTo(HaveOccurred())
+
+		secondBytes, err := ioutil.ReadFile(second)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(secondBytes).To(Equal(firstBytes))
+
+		command := exec.Command("git", "branch", "--list")
+		command.Dir = repoToPatch
+		output, err := command.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(output)).NotTo(ContainSubstring("knit-export"))
+		Expect(string(output)).NotTo(ContainSubstring("1.2.1"))
+	})
+
+	It("requires an output", func() {
+		command := exec.Command(pathToKnit, "export",
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "1m").Should(gexec.Exit(1))
+
+		Expect(session.Err).To(gbytes.Say("output is a required flag"))
+	})
+})
@@ -17,18 +17,82 @@ import (
 var buildVersion string
 
 func main() {
+	defer cleanup()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			validate(os.Args[2:])
+			exit(0)
+		case "plan":
+			plan(os.Args[2:])
+			exit(0)
+		case "export":
+			export(os.Args[2:])
+			exit(0)
+		case "verify":
+			verify(os.Args[2:])
+			exit(0)
+		}
+	}
+
 	var (
 		releaseRepository string
+		repositoryURL     string
+		cacheDir          string
 		patchesRepository string
+		patchesRef        string
+		patchManifest     string
 		version           string
+		allVersions       string
+		committerName     string
+		committerEmail    string
+		commitMessages    patcher.CommitMessages
+		eventLogPath      string
+		fetchJobs         int
+		submoduleJobs     int
+		fetchDepth        int
+		filter            string
+		publishOptions    patcher.PublishOptions
 		quiet             bool
+		dryRun            bool
+		continueRun       bool
+		abortRun          bool
+		checkOnly         bool
+		reproducible      bool
 		showBuildVersion  bool
 	)
 
 	flag.StringVar(&releaseRepository, "repository-to-patch", "", "")
+	flag.StringVar(&repositoryURL, "repository-url", "", "")
+	flag.StringVar(&cacheDir, "cache-dir", "", "")
 	flag.StringVar(&patchesRepository, "patch-repository", "", "")
+	flag.StringVar(&patchesRef, "patch-repository-ref", "", "")
+	flag.StringVar(&patchManifest, "patch-manifest", "", "")
 	flag.StringVar(&version, "version", "", "")
+	flag.StringVar(&allVersions, "all-versions", "", "")
+	flag.StringVar(&committerName, "committer-name", "", "")
+	flag.StringVar(&committerEmail, "committer-email", "", "")
+	flag.StringVar(&commitMessages.Bump, "bump-message", "", "")
+	flag.StringVar(&commitMessages.Addition, "addition-message", "", "")
+	flag.StringVar(&commitMessages.Removal, "removal-message", "", "")
+	flag.StringVar(&commitMessages.SubmodulePatch, "submodule-patch-message", "", "")
+	flag.StringVar(&commitMessages.Fork, "fork-message", "", "")
+	flag.StringVar(&eventLogPath, "event-log", "", "")
+	flag.IntVar(&fetchJobs, "fetch-jobs", 4, "")
+	flag.IntVar(&submoduleJobs, "submodule-jobs", 4, "")
+	flag.IntVar(&fetchDepth, "depth", 0, "")
+	flag.StringVar(&filter, "filter", "", "")
+	flag.BoolVar(&publishOptions.Tag, "tag", false, "")
+	flag.StringVar(&publishOptions.TagPrefix, "tag-prefix", "v", "")
+	flag.StringVar(&publishOptions.Remote, "push-remote", "", "")
+	flag.StringVar(&publishOptions.SubmoduleRemote, "submodule-push-remote", "origin", "")
 	flag.BoolVar(&quiet, "quiet", false, "")
+	flag.BoolVar(&dryRun, "dry-run", false, "")
+	flag.BoolVar(&continueRun, "continue", false, "")
+	flag.BoolVar(&abortRun, "abort", false, "")
+	flag.BoolVar(&checkOnly, "check", false, "")
+	flag.BoolVar(&reproducible, "reproducible", false, "")
 	flag.BoolVar(&showBuildVersion, "v", false, "")
 	flag.Parse()
 
@@ -38,51 +102,368 @@ func main() {
 		}
 
 		fmt.Printf("Knit version: %s\n", buildVersion)
-		os.Exit(0)
+		exit(0)
 	}
 
+	resuming := continueRun || abortRun
+
 	var missingFlag string
 	switch {
-	case releaseRepository == "":
+	case continueRun && abortRun:
+		missingFlag = "continue and abort cannot be used together"
+	case checkOnly && resuming:
+		missingFlag = "check cannot be used with continue or abort"
+	case version != "" && allVersions != "":
+		missingFlag = "version and all-versions cannot be used together"
+	case cacheDir != "" && repositoryURL == "":
+		missingFlag = "cache-dir requires repository-url"
+	case fetchJobs < 1:
+		missingFlag = "fetch-jobs must be at least 1"
+	case submoduleJobs < 1:
+		missingFlag = "submodule-jobs must be at least 1"
+	case fetchDepth < 0:
+		missingFlag = "depth cannot be negative"
+	case releaseRepository == "" && !dryRun:
 		missingFlag = "repository-to-patch is a required flag"
-	case patchesRepository == "":
+	case patchesRepository != "" && patchManifest != "":
+		missingFlag = "patch-repository and patch-manifest cannot be used together"
+	case patchesRef != "" && patchesRepository == "":
+		missingFlag = "patch-repository-ref requires patch-repository"
+	case patchesRepository == "" && patchManifest == "" && !resuming:
 		missingFlag = "patch-repository is a required flag"
-	case version == "":
+	case version == "" && allVersions == "" && !resuming:
 		missingFlag = "version is a required flag"
 	}
 
 	if missingFlag != "" {
-		log.Fatal(missingFlag)
+		fatal(missingFlag)
+	}
+
+	err := commitMessages.Validate()
+	if err != nil {
+		fatal(err)
 	}
 
 	gitPath, err := exec.LookPath("git")
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	versionsParser := patcher.NewVersionsParser(version, patcher.NewPatchSet(patchesRepository))
 	runner, err := patcher.NewCommandRunner(gitPath, quiet)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	if eventLogPath != "" {
+		eventLog, err := os.OpenFile(eventLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fatal(err)
+		}
+		defer eventLog.Close()
+
+		runner.EventLog = patcher.NewEventLog(eventLog)
 	}
 
 	err = checkGitVersion(runner)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	patchSet, patchRef, err := newPatchSet(runner, patchesRepository, patchesRef, patchManifest, dryRun)
+	if err != nil {
+		fatal(err)
+	}
+
+	if dryRun {
+		checkpoints, err := getCheckpoints(patchSet, version, allVersions)
+		if err != nil {
+			fatal(err)
+		}
+
+		err = patcher.NewApply(patcher.NewDryRun(os.Stdout), nil).Checkpoints(checkpoints)
+		if err != nil {
+			fatal(err)
+		}
+
+		exit(0)
+	}
+
+	if repositoryURL != "" && !resuming {
+		cloner := patcher.NewCloner(runner, cacheDir)
+		cloner.SubmoduleJobs = submoduleJobs
+		cloner.FetchDepth = fetchDepth
+		cloner.Filter = filter
+
+		err = cloner.Clone(repositoryURL, releaseRepository)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	committerName, err = committerIdentity(runner, releaseRepository, committerName, "GIT_COMMITTER_NAME", "user.name")
+	if err != nil {
+		fatal(err)
+	}
+
+	committerEmail, err = committerIdentity(runner, releaseRepository, committerEmail, "GIT_COMMITTER_EMAIL", "user.email")
+	if err != nil {
+		fatal(err)
+	}
+
+	var commitDate string
+	if reproducible {
+		commitDate, err = sourceDateEpoch()
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	newRepo := func(path string, messages patcher.CommitMessages) patcher.Repo {
+		repo := patcher.NewRepo(runner, path, committerName, committerEmail)
+		repo.CommitMessageOverrides = commitMessages
+		repo.SetCommitMessages(messages)
+		repo.Reproducible = reproducible
+		repo.CommitDate = commitDate
+		repo.FetchJobs = fetchJobs
+		repo.SubmoduleJobs = submoduleJobs
+		repo.FetchDepth = fetchDepth
+		repo.Filter = filter
+		return repo
+	}
+
+	repo := newRepo(releaseRepository, patcher.CommitMessages{})
+
+	if checkOnly {
+		checkpoints, err := getCheckpoints(patchSet, version, allVersions)
+		if err != nil {
+			fatal(err)
+		}
+
+		var failed bool
+		for _, checkpoint := range checkpoints {
+			err = check(repo, newRepo, checkpoint)
+			if err != nil {
+				log.Printf("%s: %s", checkpoint.FinalBranch, err)
+				failed = true
+				continue
+			}
+
+			fmt.Printf("all changes for %s apply cleanly\n", checkpoint.FinalBranch)
+		}
+
+		if failed {
+			exit(1)
+		}
+
+		exit(0)
+	}
+
+	statePath, err := repo.GitPath("knit-state.json")
+	if err != nil {
+		fatal(err)
+	}
+
+	stateFile := patcher.NewStateFile(statePath)
+
+	inProgress, err := stateFile.Exists()
+	if err != nil {
+		fatal(err)
+	}
+
+	if resuming {
+		if !inProgress {
+			fatal("there is no knit run in progress to continue or abort")
+		}
+
+		state, err := stateFile.Load()
+		if err != nil {
+			fatal(err)
+		}
+
+		state, err = restorePatches(runner, state)
+		if err != nil {
+			fatal(err)
+		}
+
+		apply := patcher.NewApply(&repo, stateFile)
+		apply.EventLog = runner.EventLog
+		apply.PatchRef = state.PatchRef
+
+		if abortRun {
+			err = apply.Abort(state)
+			if err != nil {
+				fatal(err)
+			}
+
+			exit(0)
+		}
+
+		err = apply.Continue(state)
+		if err != nil {
+			fatalWithResumeHint(err, stateFile)
+		}
+
+		err = patcher.NewPublish(repo, publishOptions).Checkpoints(state.Checkpoints())
+		if err != nil {
+			fatal(err)
+		}
+
+		exit(0)
+	}
+
+	if inProgress {
+		fatal("a knit run is already in progress. Please run knit with --continue or --abort")
+	}
+
+	checkpoints, err := getCheckpoints(patchSet, version, allVersions)
+	if err != nil {
+		fatal(err)
 	}
 
-	repo := patcher.NewRepo(runner, releaseRepository, "bot", "witchcraft@example.com")
-	apply := patcher.NewApply(repo)
+	apply := patcher.NewApply(&repo, stateFile)
+	apply.EventLog = runner.EventLog
+	apply.PatchRef = patchRef
 
-	initialCheckpoint, err := versionsParser.GetCheckpoint()
+	err = apply.Checkpoints(checkpoints)
 	if err != nil {
-		log.Fatal(err)
+		fatalWithResumeHint(err, stateFile)
 	}
 
-	err = apply.Checkpoint(initialCheckpoint)
+	err = patcher.NewPublish(repo, publishOptions).Checkpoints(checkpoints)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+}
+
+func getCheckpoints(patchSet patcher.PatchSet, version, allVersions string) ([]patcher.Checkpoint, error) {
+	versions := []string{version}
+	if allVersions != "" {
+		var err error
+		versions, err = patchSet.AllVersions(allVersions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var checkpoints []patcher.Checkpoint
+	for _, v := range versions {
+		checkpoint, err := patcher.NewVersionsParser(v, patchSet).GetCheckpoint()
+		if err != nil {
+			return nil, err
+		}
+
+		checkpoints = append(checkpoints, checkpoint)
 	}
+
+	return checkpoints, nil
+}
+
+func newPatchSet(runner patcher.CommandRunner, patchesRepository, patchesRef, patchManifest string, namesOnly bool) (patcher.PatchSet, *patcher.PatchRef, error) {
+	switch {
+	case patchManifest != "":
+		return patcher.NewPatchSetFromSource(patcher.NewManifestSource(patchManifest)), nil, nil
+	case patchesRef != "":
+		source, err := patcher.NewGitRefSource(runner, patchesRepository, patchesRef)
+		if err != nil {
+			return patcher.PatchSet{}, nil, err
+		}
+		cleanups = append(cleanups, source.Close)
+
+		if namesOnly {
+			return patcher.NewPatchSetFromSource(source.Names()), nil, nil
+		}
+
+		ref := source.PatchRef()
+		return patcher.NewPatchSetFromSource(source), &ref, nil
+	default:
+		return patcher.NewPatchSet(patchesRepository), nil, nil
+	}
+}
+
+func restorePatches(runner patcher.CommandRunner, state patcher.State) (patcher.State, error) {
+	if state.PatchRef == nil {
+		return state, nil
+	}
+
+	source, err := patcher.ReopenGitRefSource(runner, *state.PatchRef)
+	if err != nil {
+		return patcher.State{}, err
+	}
+	cleanups = append(cleanups, source.Close)
+
+	return source.Restore(state)
+}
+
+func fatalWithResumeHint(err error, stateFile patcher.StateFile) {
+	inProgress, _ := stateFile.Exists()
+	if inProgress {
+		fatalf("%s\nfix the failure and run knit with --continue to resume, or with --abort to start over", err)
+	}
+
+	fatal(err)
+}
+
+var cleanups []func() error
+
+func cleanup() {
+	for _, c := range cleanups {
+		c()
+	}
+	cleanups = nil
+}
+
+func fatal(v ...interface{}) {
+	cleanup()
+	log.Fatal(v...)
+}
+
+func fatalf(format string, v ...interface{}) {
+	cleanup()
+	log.Fatalf(format, v...)
+}
+
+func exit(code int) {
+	cleanup()
+	os.Exit(code)
+}
+
+func sourceDateEpoch() (string, error) {
+	value := os.Getenv("SOURCE_DATE_EPOCH")
+	if value == "" {
+		return "", nil
+	}
+
+	epoch, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || epoch < 0 {
+		return "", fmt.Errorf("SOURCE_DATE_EPOCH must be a number of seconds since the epoch, got %q", value)
+	}
+
+	return fmt.Sprintf("@%d +0000", epoch), nil
+}
+
+func committerIdentity(runner patcher.CommandRunner, repo, value, envVar, configKey string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+
+	if value = os.Getenv(envVar); value != "" {
+		return value, nil
+	}
+
+	out, err := runner.CombinedOutput(patcher.Command{
+		Args: []string{"config", "--get", configKey},
+		Dir:  repo,
+	})
+	if err == nil {
+		value = strings.TrimSpace(string(out))
+	}
+
+	if value == "" {
+		flagName := "committer-" + strings.TrimPrefix(configKey, "user.")
+		return "", fmt.Errorf("could not determine the committer %s. Please provide --%s, set %s or configure %s in the repository", strings.TrimPrefix(configKey, "user."), flagName, envVar, configKey)
+	}
+
+	return value, nil
 }
 
 func checkGitVersion(runner patcher.CommandRunner) error {
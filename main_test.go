@@ -1,12 +1,16 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/pivotal-cf/knit/patcher"
 
 	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
@@ -73,6 +77,276 @@ var _ = Describe("Apply Patches", func() {
 		Expect(string(session.Out.Contents())).NotTo(ContainSubstring("a hotfix patch"))
 	})
 
+	It("commits as the provided committer and keeps the patch author", func() {
+		command := exec.Command(pathToKnit,
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-committer-name", "Release Bot",
+			"-committer-email", "release-bot@example.com",
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "10m").Should(gexec.Exit(0))
+
+		command = exec.Command("git", "log", "--format=%an <%ae>|%cn <%ce>", "-n", "1")
+		command.Dir = repoToPatch
+		session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(string(session.Out.Contents())).To(Equal("Knit Acceptance Test Committer <cf-release-engineering@pivotal.io>|Release Bot <release-bot@example.com>\n"))
+	})
+
+	It("commits as the repository's configured user by default", func() {
+		command := exec.Command(pathToKnit,
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "10m").Should(gexec.Exit(0))
+
+		command = exec.Command("git", "log", "--format=%cn <%ce>", "-n", "1")
+		command.Dir = repoToPatch
+		session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(string(session.Out.Contents())).To(Equal("Knit Acceptance Test Committer <cf-release-engineering@pivotal.io>\n"))
+	})
+
+	Context("when the reproducible flag is provided", func() {
+		git := func(args ...string) string {
+			command := exec.Command("git", args...)
+			command.Dir = repoToPatch
+			output, err := command.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(output))
+
+			return strings.TrimSpace(string(output))
+		}
+
+		knit := func(env ...string) {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1",
+				"-reproducible")
+			command.Env = append(os.Environ(), env...)
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "10m").Should(gexec.Exit(0))
+		}
+
+		It("builds the same commits every time", func() {
+			knit()
+			sha := git("rev-parse", "1.2.1")
+			Expect(git("log", "-1", "--format=%ct", "1.2.1")).To(Equal(git("log", "-1", "--format=%ct", "master")))
+
+			git("checkout", "master")
+			git("branch", "-D", "1.2.1")
+
+			knit()
+			Expect(git("rev-parse", "1.2.1")).To(Equal(sha))
+		})
+
+		It("removes several submodules in the same order every time", func() {
+			submodulesDir, err := ioutil.TempDir("", "submodules")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(submodulesDir)
+
+			paths := []string{"src/a", "src/b", "src/c", "src/d"}
+			for _, path := range paths {
+				submodule := filepath.Join(submodulesDir, filepath.Base(path))
+				err = os.Mkdir(submodule, 0755)
+				Expect(err).NotTo(HaveOccurred())
+
+				initGitRepo(submodule)
+				git("-c", "protocol.file.allow=always", "submodule", "add", submodule, path)
+			}
+			git("commit", "-m", "add submodules")
+			git("tag", "with-submodules")
+
+			err = os.Mkdir(filepath.Join(patchesDir, "1.3"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = ioutil.WriteFile(filepath.Join(patchesDir, "1.3", "starting-versions.yml"), []byte(`---
+starting_versions:
+- version: 1
+  ref: with-submodules
+  submodules:
+    "src/c": { remove: true }
+    "src/a": { remove: true }
+    "src/d": { remove: true }
+    "src/b": { remove: true }`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			knit := func() {
+				command := exec.Command(pathToKnit,
+					"-repository-to-patch", repoToPatch,
+					"-patch-repository", patchesDir,
+					"-version", "1.3.1",
+					"-reproducible")
+				command.Env = append(os.Environ(), "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=protocol.file.allow", "GIT_CONFIG_VALUE_0=always")
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session, "10m").Should(gexec.Exit(0))
+			}
+
+			knit()
+			sha := git("rev-parse", "1.3.1")
+			Expect(git("log", "--format=%s", "with-submodules..1.3.1")).To(Equal(strings.Join([]string{
+				"Knit removal of submodule 'src/d'",
+				"Knit removal of submodule 'src/c'",
+				"Knit removal of submodule 'src/b'",
+				"Knit removal of submodule 'src/a'",
+			}, "\n")))
+
+			git("checkout", "with-submodules")
+			git("branch", "-D", "1.3.1")
+
+			knit()
+			Expect(git("rev-parse", "1.3.1")).To(Equal(sha))
+		})
+
+		It("dates the commits with SOURCE_DATE_EPOCH when it is set", func() {
+			knit("SOURCE_DATE_EPOCH=1500000000")
+
+			Expect(git("log", "-1", "--format=%ct", "1.2.1")).To(Equal("1500000000"))
+		})
+
+		It("rejects a SOURCE_DATE_EPOCH that is not a number", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1",
+				"-reproducible")
+			command.Env = append(os.Environ(), "SOURCE_DATE_EPOCH=yesterday")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "30s").Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say(`SOURCE_DATE_EPOCH must be a number of seconds since the epoch, got "yesterday"`))
+		})
+	})
+
+	It("clones the repository to patch from a URL through the cache", func() {
+		cacheDir, err := ioutil.TempDir("", "cache-dir")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(cacheDir)
+
+		clonePath := filepath.Join(cacheDir, "clone")
+
+		command := exec.Command(pathToKnit,
+			"-repository-url", repoToPatch,
+			"-cache-dir", cacheDir,
+			"-repository-to-patch", clonePath,
+			"-patch-repository", patchesDir,
+			"-committer-name", "Release Bot",
+			"-committer-email", "release-bot@example.com",
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "10m").Should(gexec.Exit(0))
+
+		mirrors, err := filepath.Glob(filepath.Join(cacheDir, "*.git"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mirrors).To(HaveLen(1))
+
+		command = exec.Command("git", "log", "--format=%s", "-n", "1", "1.2.1")
+		command.Dir = clonePath
+		session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session).Should(gexec.Exit(0))
+		Expect(string(session.Out.Contents())).To(Equal("a change to the file\n"))
+	})
+
+	It("tags the final branch and pushes it to the remote", func() {
+		remote, err := ioutil.TempDir("", "remote")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(remote)
+
+		command := exec.Command("git", "init", "--bare", remote)
+		output, err := command.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(output))
+
+		command = exec.Command(pathToKnit,
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-tag",
+			"-push-remote", remote,
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "10m").Should(gexec.Exit(0))
+
+		command = exec.Command("git", "rev-parse", "1.2.1", "v1.2.1")
+		command.Dir = repoToPatch
+		local, err := command.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(local))
+
+		command = exec.Command("git", "rev-parse", "1.2.1", "v1.2.1")
+		command.Dir = remote
+		pushed, err := command.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(pushed))
+
+		Expect(string(pushed)).To(Equal(string(local)))
+	})
+
+	It("writes every git command to the event log", func() {
+		eventLog := filepath.Join(patchesDir, "events.jsonl")
+
+		command := exec.Command(pathToKnit,
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-event-log", eventLog,
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "10m").Should(gexec.Exit(0))
+
+		contents, err := ioutil.ReadFile(eventLog)
+		Expect(err).NotTo(HaveOccurred())
+
+		var steps []string
+		for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+			var event patcher.Event
+			Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+			steps = append(steps, event.Step)
+
+			if event.Step == "ApplyPatch" {
+				Expect(event.Dir).To(Equal(repoToPatch))
+				Expect(event.ExitCode).To(Equal(0))
+				Expect(event.Output).To(ContainSubstring("Applying: a change to the file"))
+				Expect(event.Branch).To(Equal("1.2.1"))
+				Expect(event.Change).To(Equal(new(int)))
+				Expect(event.Description).To(Equal(fmt.Sprintf("apply patch %s", filepath.Join(patchesDir, "1.2", "change.patch"))))
+			}
+
+			if event.Step == "CheckoutBranch" {
+				Expect(event.Change).To(BeNil())
+				Expect(event.Description).To(BeEmpty())
+			}
+		}
+
+		Expect(steps).To(ContainElement("Checkout"))
+		Expect(steps).To(ContainElement("CheckoutBranch"))
+		Expect(steps).To(ContainElement("ApplyPatch"))
+	})
+
+	It("rejects an invalid commit message template", func() {
+		command := exec.Command(pathToKnit,
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-bump-message", "Bump {{.Path",
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "30s").Should(gexec.Exit(1))
+
+		Expect(session.Err).To(gbytes.Say("invalid bump commit message template"))
+	})
+
 	It("does not print any logs when --quiet flag is provided", func() {
 		command := exec.Command(pathToKnit,
 			"-repository-to-patch", repoToPatch,
@@ -87,6 +361,339 @@ var _ = Describe("Apply Patches", func() {
 		Expect(session.Out).NotTo(gbytes.Say("a change to the file"))
 	})
 
+	Context("when the dry-run flag is provided", func() {
+		It("prints the plan without touching the repository", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1+hot.fix",
+				"-dry-run")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "1m").Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say("checkout master"))
+			Expect(session.Out).To(gbytes.Say(`create branch 1.2.1\+hot.fix`))
+			Expect(session.Out).To(gbytes.Say("apply patch " + filepath.Join(patchesDir, "1.2", "change.patch")))
+			Expect(session.Out).To(gbytes.Say("apply patch " + filepath.Join(patchesDir, "1.2", "change2.patch")))
+
+			command = exec.Command("git", "branch", "--list", "1.2.1*")
+			command.Dir = repoToPatch
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session, "30s").Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).To(BeEmpty())
+		})
+	})
+
+	Context("when the all-versions flag is provided", func() {
+		It("builds every version of the minor line on top of the previous one", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-all-versions", "1.2",
+				"-dry-run")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "1m").Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say("checkout master"))
+			Expect(session.Out).To(gbytes.Say(`create branch 1.2.1\n`))
+			Expect(session.Out).To(gbytes.Say("checkout master"))
+			Expect(session.Out).To(gbytes.Say(`create branch 1.2.1\+hot.fix`))
+			Expect(session.Out).To(gbytes.Say("checkout 1.2.1\n"))
+			Expect(session.Out).To(gbytes.Say("create branch 1.2.2"))
+			Expect(session.Out).To(gbytes.Say("add submodule path/to/kiln"))
+			Expect(session.Out).To(gbytes.Say("checkout 1.2.2"))
+			Expect(session.Out).To(gbytes.Say("create branch 1.2.3"))
+			Expect(session.Out).To(gbytes.Say("remove submodule path/to/kiln"))
+		})
+
+		It("cannot be combined with the version flag", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1",
+				"-all-versions", "1.2")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "30s").Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say("version and all-versions cannot be used together"))
+		})
+	})
+
+	Context("when the check flag is provided", func() {
+		It("applies everything in a throwaway worktree", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1+hot.fix",
+				"-check")
+
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "5m").Should(gexec.Exit(0))
+
+			Expect(session.Out).To(gbytes.Say(`all changes for 1.2.1\+hot.fix apply cleanly`))
+
+			command = exec.Command("git", "status")
+			command.Dir = repoToPatch
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session, "30s").Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).To(ContainSubstring("On branch master"))
+
+			command = exec.Command("git", "for-each-ref", "refs/heads")
+			command.Dir = repoToPatch
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session, "30s").Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).NotTo(ContainSubstring("knit-check"))
+			Expect(string(session.Out.Contents())).NotTo(ContainSubstring("1.2.1"))
+
+			command = exec.Command("git", "worktree", "list")
+			command.Dir = repoToPatch
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session, "30s").Should(gexec.Exit(0))
+			Expect(strings.Count(string(session.Out.Contents()), "\n")).To(Equal(1))
+		})
+
+		Context("when a patch does not apply", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(filepath.Join(repoToPatch, "file-in-repo.txt"), []byte("a conflicting change"), os.ModePerm)
+				Expect(err).NotTo(HaveOccurred())
+
+				for _, args := range [][]string{
+					{"add", "."},
+					{"commit", "-m", "a conflicting change"},
+				} {
+					command := exec.Command("git", args...)
+					command.Dir = repoToPatch
+					session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(session, "30s").Should(gexec.Exit(0))
+				}
+			})
+
+			It("reports the first patch that fails", func() {
+				command := exec.Command(pathToKnit,
+					"-repository-to-patch", repoToPatch,
+					"-patch-repository", patchesDir,
+					"-version", "1.2.1",
+					"-check")
+
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session, "5m").Should(gexec.Exit(1))
+
+				Expect(session.Err).To(gbytes.Say("check failed at change 1 of 1: apply patch " + filepath.Join(patchesDir, "1.2", "change.patch")))
+
+				command = exec.Command("git", "for-each-ref", "refs/heads")
+				command.Dir = repoToPatch
+				session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session, "30s").Should(gexec.Exit(0))
+				Expect(string(session.Out.Contents())).NotTo(ContainSubstring("knit-check"))
+			})
+		})
+	})
+
+	Context("when a patch read from a git ref fails to apply", func() {
+		var tmpDir string
+
+		git := func(dir string, args ...string) {
+			command := exec.Command("git", args...)
+			command.Dir = dir
+			output, err := command.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("Error: %s", output))
+		}
+
+		knit := func(args ...string) *gexec.Session {
+			command := exec.Command(pathToKnit, append([]string{"-repository-to-patch", repoToPatch}, args...)...)
+			command.Env = append(os.Environ(), fmt.Sprintf("TMPDIR=%s", tmpDir))
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			return session
+		}
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "knit-tmp")
+			Expect(err).NotTo(HaveOccurred())
+
+			git(patchesDir, "init")
+			git(patchesDir, "add", "-A")
+			git(patchesDir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "patches")
+			git(patchesDir, "tag", "pinned")
+
+			err = ioutil.WriteFile(filepath.Join(repoToPatch, "file-in-repo.txt"), []byte("a conflicting change"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			git(repoToPatch, "add", ".")
+			git(repoToPatch, "commit", "-m", "a conflicting change")
+
+			session := knit("-patch-repository", patchesDir, "-patch-repository-ref", "pinned", "-version", "1.2.1")
+			Eventually(session, "5m").Should(gexec.Exit(1))
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		It("removes the patches it read and reads them again to continue", func() {
+			entries, err := ioutil.ReadDir(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+
+			err = ioutil.WriteFile(filepath.Join(repoToPatch, "file-in-repo.txt"), []byte("a resolved change"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			git(repoToPatch, "add", "file-in-repo.txt")
+			git(repoToPatch, "-c", "user.name=test", "-c", "user.email=test@example.com", "am", "--continue")
+
+			git(patchesDir, "tag", "-d", "pinned")
+
+			session := knit("-continue")
+			Eventually(session, "5m").Should(gexec.Exit(0))
+
+			Expect(filepath.Join(repoToPatch, ".git", "knit-state.json")).NotTo(BeAnExistingFile())
+
+			entries, err = ioutil.ReadDir(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when a patch fails to apply", func() {
+		BeforeEach(func() {
+			err := ioutil.WriteFile(filepath.Join(repoToPatch, "file-in-repo.txt"), []byte("a conflicting change"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, args := range [][]string{
+				{"add", "."},
+				{"commit", "-m", "a conflicting change"},
+			} {
+				command := exec.Command("git", args...)
+				command.Dir = repoToPatch
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session, "30s").Should(gexec.Exit(0))
+			}
+
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "5m").Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say("run knit with --continue to resume, or with --abort to start over"))
+		})
+
+		It("refuses to start another run", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "1m").Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say("a knit run is already in progress"))
+		})
+
+		It("continues once git am has been resolved", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-continue")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "1m").Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say("git am is still in progress"))
+
+			err = ioutil.WriteFile(filepath.Join(repoToPatch, "file-in-repo.txt"), []byte("a resolved change"), os.ModePerm)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, args := range [][]string{
+				{"add", "file-in-repo.txt"},
+				{"am", "--continue"},
+			} {
+				command = exec.Command("git", args...)
+				command.Dir = repoToPatch
+				session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session, "30s").Should(gexec.Exit(0))
+			}
+
+			command = exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-continue")
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "5m").Should(gexec.Exit(0))
+
+			Expect(filepath.Join(repoToPatch, ".git", "knit-state.json")).NotTo(BeAnExistingFile())
+
+			command = exec.Command("git", "status")
+			command.Dir = repoToPatch
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session, "30s").Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).To(ContainSubstring("On branch 1.2.1"))
+		})
+
+		It("refuses to continue past a patch that was skipped", func() {
+			command := exec.Command("git", "am", "--skip")
+			command.Dir = repoToPatch
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "30s").Should(gexec.Exit(0))
+
+			command = exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-continue")
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "1m").Should(gexec.Exit(1))
+
+			Expect(session.Err).To(gbytes.Say("change.patch was not applied"))
+			Expect(filepath.Join(repoToPatch, ".git", "knit-state.json")).To(BeAnExistingFile())
+		})
+
+		It("aborts the run", func() {
+			command := exec.Command(pathToKnit,
+				"-repository-to-patch", repoToPatch,
+				"-abort")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "5m").Should(gexec.Exit(0))
+
+			Expect(filepath.Join(repoToPatch, ".git", "knit-state.json")).NotTo(BeAnExistingFile())
+
+			command = exec.Command("git", "branch", "--list", "1.2.1")
+			command.Dir = repoToPatch
+			session, err = gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(session, "30s").Should(gexec.Exit(0))
+			Expect(string(session.Out.Contents())).To(BeEmpty())
+		})
+	})
+
 	Context("when the version specified has no starting version", func() {
 		It("works just fine", func() {
 			command := exec.Command(pathToKnit,
@@ -265,6 +872,42 @@ var _ = Describe("Apply Patches", func() {
 				Entry("missing release repo", "v1", "", "some-patch-repo", "repository-to-patch is a required flag"),
 				Entry("missing patch repo", "v1", "some-repo-to-patch", "", "patch-repository is a required flag"),
 			)
+
+			It("does not allow continue and abort together", func() {
+				command := exec.Command(pathToKnit,
+					"-repository-to-patch", repoToPatch,
+					"-continue",
+					"-abort")
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("continue and abort cannot be used together"))
+			})
+
+			It("requires at least one fetch job", func() {
+				command := exec.Command(pathToKnit,
+					"-repository-to-patch", repoToPatch,
+					"-patch-repository", patchesDir,
+					"-version", "1.2.1",
+					"-fetch-jobs", "0")
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("fetch-jobs must be at least 1"))
+			})
+
+			It("requires a run in progress to continue", func() {
+				command := exec.Command(pathToKnit,
+					"-repository-to-patch", repoToPatch,
+					"-continue")
+				session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(session, "1m").Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("there is no knit run in progress to continue or abort"))
+			})
 		})
 
 		Context("when the git executable does not exist", func() {
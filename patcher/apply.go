@@ -1,78 +1,240 @@
 package patcher
 
-import "sort"
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
 
 type Apply struct {
-	repo repository
+	EventLog *EventLog
+	PatchRef *PatchRef
+
+	repo  repository
+	state stateStore
 }
 
 type repository interface {
+	SetCommitMessages(messages CommitMessages)
 	Checkout(checkoutRef string) error
 	CheckoutBranch(name string) error
+	DeleteBranch(name string) error
 	ApplyPatch(patch string) error
+	PatchInProgress(path string) (bool, error)
+	AbortPatch(path string) error
+	Head(path string) (string, error)
 	AddSubmodule(path, url, ref, branch string) error
 	RemoveSubmodule(path string) error
+	FetchSubmodules(bumps map[string]string)
 	BumpSubmodule(path, sha string) error
 	PatchSubmodule(path string, patch string) error
+	CommitSubmodulePatch(path string, patch string) error
+	ForkSubmodule(path, url string) error
+}
+
+type stateStore interface {
+	Save(state State) error
+	Clear() error
+}
+
+type step struct {
+	description string
+	patch       bool
+	path        string
+	apply       func() error
+	finish      func() error
+}
+
+type StepError struct {
+	Change int
+	Step   string
+	Err    error
+}
+
+func (e StepError) Error() string {
+	return e.Err.Error()
 }
 
-func NewApply(repo repository) Apply {
+func NewApply(repo repository, state stateStore) Apply {
 	return Apply{
-		repo: repo,
+		repo:  repo,
+		state: state,
 	}
 }
 
 func (a Apply) Checkpoint(checkpoint Checkpoint) error {
-	err := a.repo.Checkout(checkpoint.CheckoutRef)
+	return a.Checkpoints([]Checkpoint{checkpoint})
+}
+
+func (a Apply) Checkpoints(checkpoints []Checkpoint) error {
+	return a.checkpoints(nil, checkpoints)
+}
+
+func (a Apply) checkpoints(applied, pending []Checkpoint) error {
+	for i, checkpoint := range pending {
+		err := a.save(State{Applied: applied, Pending: pending[i:]})
+		if err != nil {
+			return err
+		}
+
+		state := State{Checkpoint: checkpoint, Applied: applied}
+		if i+1 < len(pending) {
+			state.Pending = pending[i+1:]
+		}
+
+		checkoutRef := checkpoint.CheckoutRef
+		if base, ok := findBase(applied, checkpoint); ok {
+			checkoutRef = base.FinalBranch
+			state.Change = len(base.Changes)
+		}
+
+		a.repo.SetCommitMessages(checkpoint.CommitMessages)
+
+		err = a.repo.Checkout(checkoutRef)
+		if err != nil {
+			return err
+		}
+
+		err = a.repo.CheckoutBranch(checkpoint.FinalBranch)
+		if err != nil {
+			return err
+		}
+
+		err = a.apply(state)
+		if err != nil {
+			return err
+		}
+
+		applied = append(applied, checkpoint)
+	}
+
+	return a.clear()
+}
+
+func (a Apply) Continue(state State) error {
+	if !state.Started() {
+		return a.checkpoints(state.Applied, state.Pending)
+	}
+
+	a.repo.SetCommitMessages(state.Checkpoint.CommitMessages)
+
+	if state.Change < len(state.Checkpoint.Changes) {
+		steps := a.steps(state.Checkpoint.Changes[state.Change])
+
+		if state.Step < len(steps) && steps[state.Step].patch {
+			a.EventLog.StartStep(state.Checkpoint.FinalBranch, state.Change, steps[state.Step].description)
+			err := a.finishPatch(state, steps[state.Step])
+			a.EventLog.EndStep()
+			if err != nil {
+				return err
+			}
+
+			state.Step++
+		}
+	}
+
+	err := a.apply(state)
 	if err != nil {
 		return err
 	}
 
-	err = a.repo.CheckoutBranch(checkpoint.FinalBranch)
+	return a.checkpoints(append(state.Applied, state.Checkpoint), state.Pending)
+}
+
+func (a Apply) finishPatch(state State, patchStep step) error {
+	location := "the repository"
+	if state.PatchPath != "" {
+		location = fmt.Sprintf("submodule %s", state.PatchPath)
+	}
+
+	inProgress, err := a.repo.PatchInProgress(state.PatchPath)
 	if err != nil {
 		return err
 	}
 
-	for _, change := range checkpoint.Changes {
-		for _, patch := range change.Patches {
-			err := a.repo.ApplyPatch(patch)
-			if err != nil {
-				return err
-			}
+	if inProgress {
+		return fmt.Errorf("git am is still in progress in %s. Please finish it with `git am --continue` before continuing", location)
+	}
+
+	if state.Head != "" {
+		head, err := a.repo.Head(state.PatchPath)
+		if err != nil {
+			return err
 		}
 
-		for path, addition := range change.SubmoduleAdditions {
-			err := a.repo.AddSubmodule(path, addition.URL, addition.Ref, addition.Branch)
-			if err != nil {
-				return err
-			}
+		if head == state.Head {
+			return fmt.Errorf("%s was not applied: HEAD of %s has not moved since the patch was started. Apply it with `git am`, or start over with --abort", patchStep.description, location)
 		}
+	}
 
-		for _, path := range change.SubmoduleRemovals {
-			err := a.repo.RemoveSubmodule(path)
-			if err != nil {
-				return err
-			}
+	if patchStep.finish == nil {
+		return nil
+	}
+
+	return patchStep.finish()
+}
+
+func (a Apply) Abort(state State) error {
+	err := a.repo.AbortPatch(state.PatchPath)
+	if err != nil {
+		return err
+	}
+
+	checkpoints := state.Checkpoints()
+	if len(checkpoints) > 0 {
+		err = a.repo.Checkout(checkpoints[0].CheckoutRef)
+		if err != nil {
+			return err
 		}
+	}
 
-		paths := sortSubmodules(change.Bumps)
+	branches := state.Applied
+	if state.Started() {
+		branches = append(append([]Checkpoint{}, branches...), state.Checkpoint)
+	}
 
-		for _, path := range paths {
-			sha := change.Bumps[path]
-			err := a.repo.BumpSubmodule(path, sha)
-			if err != nil {
-				return err
-			}
+	for _, checkpoint := range branches {
+		err = a.repo.DeleteBranch(checkpoint.FinalBranch)
+		if err != nil {
+			return err
 		}
+	}
 
-		submodulePaths := sortSubmodulePatches(change.SubmodulePatches)
+	return a.clear()
+}
 
-		for _, submodulePath := range submodulePaths {
-			for _, patch := range change.SubmodulePatches[submodulePath] {
-				err := a.repo.PatchSubmodule(submodulePath, patch)
+func (a Apply) apply(state State) error {
+	changes := state.Checkpoint.Changes
+
+	for ; state.Change < len(changes); state.Change, state.Step = state.Change+1, 0 {
+		steps := a.steps(changes[state.Change])
+
+		for ; state.Step < len(steps); state.Step++ {
+			state.PatchPath, state.Head = "", ""
+			if steps[state.Step].patch {
+				head, err := a.repo.Head(steps[state.Step].path)
 				if err != nil {
 					return err
 				}
+
+				state.PatchPath, state.Head = steps[state.Step].path, head
+			}
+
+			err := a.save(state)
+			if err != nil {
+				return err
+			}
+
+			a.EventLog.StartStep(state.Checkpoint.FinalBranch, state.Change, steps[state.Step].description)
+			err = steps[state.Step].apply()
+			a.EventLog.EndStep()
+			if err != nil {
+				return StepError{
+					Change: state.Change,
+					Step:   steps[state.Step].description,
+					Err:    err,
+				}
 			}
 		}
 	}
@@ -80,6 +242,145 @@ func (a Apply) Checkpoint(checkpoint Checkpoint) error {
 	return nil
 }
 
+func (a Apply) steps(change Changeset) []step {
+	var steps []step
+
+	for _, patch := range change.Patches {
+		patch := patch
+		steps = append(steps, step{
+			description: fmt.Sprintf("apply patch %s", patch),
+			patch:       true,
+			apply: func() error {
+				return a.repo.ApplyPatch(patch)
+			},
+		})
+	}
+
+	additionPaths := sortSubmoduleAdditions(change.SubmoduleAdditions)
+
+	for _, path := range additionPaths {
+		path := path
+		addition := change.SubmoduleAdditions[path]
+		steps = append(steps, step{
+			description: fmt.Sprintf("add submodule %s", path),
+			apply: func() error {
+				return a.repo.AddSubmodule(path, addition.URL, addition.Ref, addition.Branch)
+			},
+		})
+	}
+
+	for _, path := range change.SubmoduleRemovals {
+		path := path
+		steps = append(steps, step{
+			description: fmt.Sprintf("remove submodule %s", path),
+			apply: func() error {
+				return a.repo.RemoveSubmodule(path)
+			},
+		})
+	}
+
+	paths := sortSubmodules(change.Bumps)
+
+	if len(paths) > 0 {
+		bumps := change.Bumps
+		steps = append(steps, step{
+			description: fmt.Sprintf("fetch submodules %s", strings.Join(paths, ", ")),
+			apply: func() error {
+				a.repo.FetchSubmodules(bumps)
+				return nil
+			},
+		})
+	}
+
+	for _, path := range paths {
+		path := path
+		sha := change.Bumps[path]
+		steps = append(steps, step{
+			description: fmt.Sprintf("bump submodule %s to %s", path, sha),
+			apply: func() error {
+				return a.repo.BumpSubmodule(path, sha)
+			},
+		})
+	}
+
+	submodulePaths := sortSubmodulePatches(change.SubmodulePatches)
+
+	for _, submodulePath := range submodulePaths {
+		submodulePath := submodulePath
+		for _, patch := range change.SubmodulePatches[submodulePath] {
+			patch := patch
+			steps = append(steps, step{
+				description: fmt.Sprintf("patch submodule %s with %s", submodulePath, patch),
+				patch:       true,
+				path:        submodulePath,
+				apply: func() error {
+					return a.repo.PatchSubmodule(submodulePath, patch)
+				},
+				finish: func() error {
+					return a.repo.CommitSubmodulePatch(submodulePath, patch)
+				},
+			})
+		}
+	}
+
+	forkPaths := sortSubmodules(change.SubmoduleForks)
+
+	for _, path := range forkPaths {
+		path := path
+		url := change.SubmoduleForks[path]
+		steps = append(steps, step{
+			description: fmt.Sprintf("fork submodule %s to %s", path, url),
+			apply: func() error {
+				return a.repo.ForkSubmodule(path, url)
+			},
+		})
+	}
+
+	return steps
+}
+
+func (a Apply) save(state State) error {
+	if a.state == nil {
+		return nil
+	}
+
+	state.PatchRef = a.PatchRef
+
+	return a.state.Save(state)
+}
+
+func (a Apply) clear() error {
+	if a.state == nil {
+		return nil
+	}
+
+	return a.state.Clear()
+}
+
+func findBase(applied []Checkpoint, checkpoint Checkpoint) (Checkpoint, bool) {
+	var (
+		base  Checkpoint
+		found bool
+	)
+
+	for _, candidate := range applied {
+		if candidate.CheckoutRef != checkpoint.CheckoutRef {
+			continue
+		}
+
+		if len(candidate.Changes) > len(checkpoint.Changes) || (found && len(candidate.Changes) <= len(base.Changes)) {
+			continue
+		}
+
+		if reflect.DeepEqual(candidate.Changes, checkpoint.Changes[:len(candidate.Changes)]) {
+			base = candidate
+			found = true
+		}
+	}
+
+	return base, found
+}
+
 func sortSubmodules(submodules map[string]string) []string {
 	var sortedPaths []string
 
@@ -103,3 +404,15 @@ func sortSubmodulePatches(submodulePatches map[string][]string) []string {
 
 	return sortedPaths
 }
+
+func sortSubmoduleAdditions(submoduleAdditions map[string]SubmoduleAddition) []string {
+	var sortedPaths []string
+
+	for path, _ := range submoduleAdditions {
+		sortedPaths = append(sortedPaths, path)
+	}
+
+	sort.Strings(sortedPaths)
+
+	return sortedPaths
+}
@@ -1,6 +1,7 @@
 package patcher_test
 
 import (
+	"bytes"
 	"errors"
 
 	"github.com/pivotal-cf/knit/patcher"
@@ -12,12 +13,14 @@ import (
 
 var _ = Describe("Apply", func() {
 	var repo *fakes.Repository
+	var state *fakes.StateStore
 	var apply patcher.Apply
 	var checkpoint patcher.Checkpoint
 
 	BeforeEach(func() {
 		repo = &fakes.Repository{}
-		apply = patcher.NewApply(repo)
+		state = &fakes.StateStore{}
+		apply = patcher.NewApply(repo, state)
 		checkpoint = patcher.Checkpoint{
 			Changes: []patcher.Changeset{
 				{
@@ -108,6 +111,16 @@ var _ = Describe("Apply", func() {
 			}))
 		})
 
+		It("fetches the bumped submodules of every change before bumping them", func() {
+			err := apply.Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.FetchSubmodulesCall.Receives.Bumps).To(Equal([]map[string]string{
+				{"src/some-path": "some-other-sha"},
+				{"src/some-other-path": "a-sha"},
+			}))
+		})
+
 		It("patches individual submodules", func() {
 			err := apply.Checkpoint(checkpoint)
 			Expect(err).NotTo(HaveOccurred())
@@ -116,6 +129,79 @@ var _ = Describe("Apply", func() {
 			Expect(repo.PatchSubmoduleCall.Receives.Patches).To(Equal([]string{"path/to/other.patch", "path/to/different.patch"}))
 		})
 
+		It("forks submodules after patching them", func() {
+			checkpoint.Changes[1].SubmoduleForks = map[string]string{
+				"src/some-other-sub/path": "https://example.com/fork.git",
+			}
+
+			dryRunOut := bytes.NewBuffer([]byte{})
+			err := patcher.NewApply(patcher.NewDryRun(dryRunOut), nil).Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dryRunOut.String()).To(HaveSuffix(`patch submodule src/some-other-sub/path with path/to/different.patch
+fork submodule src/some-other-sub/path to https://example.com/fork.git
+`))
+
+			err = apply.Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.ForkSubmoduleCall.Receives.Forks).To(Equal(map[string]string{
+				"src/some-other-sub/path": "https://example.com/fork.git",
+			}))
+		})
+
+		It("records the progress before every step", func() {
+			err := apply.Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(state.SaveCall.Receives.States).To(Equal([]patcher.State{
+				{Pending: []patcher.Checkpoint{checkpoint}},
+				{Checkpoint: checkpoint, Change: 0, Step: 0},
+				{Checkpoint: checkpoint, Change: 0, Step: 1},
+				{Checkpoint: checkpoint, Change: 0, Step: 2},
+				{Checkpoint: checkpoint, Change: 0, Step: 3},
+				{Checkpoint: checkpoint, Change: 0, Step: 4},
+				{Checkpoint: checkpoint, Change: 0, Step: 5},
+				{Checkpoint: checkpoint, Change: 0, Step: 6, PatchPath: "src/sub/path"},
+				{Checkpoint: checkpoint, Change: 1, Step: 0},
+				{Checkpoint: checkpoint, Change: 1, Step: 1},
+				{Checkpoint: checkpoint, Change: 1, Step: 2},
+				{Checkpoint: checkpoint, Change: 1, Step: 3, PatchPath: "src/some-other-sub/path"},
+			}))
+		})
+
+		It("records where each patch is applied and the commit it starts from", func() {
+			repo.HeadCall.Returns.SHAs = []string{"head-1", "sub-head", "head-2", "other-sub-head"}
+
+			err := apply.Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.HeadCall.Receives.Paths).To(Equal([]string{"", "src/sub/path", "", "src/some-other-sub/path"}))
+
+			states := state.SaveCall.Receives.States
+			Expect(states[1]).To(Equal(patcher.State{Checkpoint: checkpoint, Change: 0, Step: 0, Head: "head-1"}))
+			Expect(states[2]).To(Equal(patcher.State{Checkpoint: checkpoint, Change: 0, Step: 1}))
+			Expect(states[7]).To(Equal(patcher.State{Checkpoint: checkpoint, Change: 0, Step: 6, PatchPath: "src/sub/path", Head: "sub-head"}))
+		})
+
+		It("records where the patches were read from", func() {
+			apply.PatchRef = &patcher.PatchRef{Repository: "/patches", Ref: "pinned", Commit: "some-sha", Dir: "/tmp/knit-patches"}
+
+			err := apply.Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, saved := range state.SaveCall.Receives.States {
+				Expect(saved.PatchRef).To(Equal(apply.PatchRef))
+			}
+		})
+
+		It("clears the progress once every change has been applied", func() {
+			err := apply.Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(state.ClearCall.WasCalled).To(BeTrue())
+		})
+
 		Context("when an error occurs", func() {
 			Context("when checkout fails", func() {
 				It("returns an error", func() {
@@ -200,6 +286,325 @@ var _ = Describe("Apply", func() {
 					Expect(err).To(MatchError("meow"))
 				})
 			})
+
+			Context("when a step fails", func() {
+				It("leaves the failed step recorded", func() {
+					repo.BumpSubmoduleCall.Returns.Error = errors.New("meow")
+
+					err := apply.Checkpoint(checkpoint)
+					Expect(err).To(MatchError("meow"))
+
+					states := state.SaveCall.Receives.States
+					Expect(states[len(states)-1]).To(Equal(patcher.State{Checkpoint: checkpoint, Change: 0, Step: 5}))
+					Expect(state.ClearCall.WasCalled).To(BeFalse())
+				})
+
+				It("describes the failed step", func() {
+					repo.PatchSubmoduleCall.Returns.Error = errors.New("meow")
+
+					err := apply.Checkpoint(checkpoint)
+					Expect(err).To(Equal(patcher.StepError{
+						Change: 0,
+						Step:   "patch submodule src/sub/path with path/to/other.patch",
+						Err:    errors.New("meow"),
+					}))
+				})
+			})
+
+			Context("when recording the progress fails", func() {
+				It("returns an error", func() {
+					state.SaveCall.Returns.Error = errors.New("meow")
+
+					err := apply.Checkpoint(checkpoint)
+					Expect(err).To(MatchError("meow"))
+					Expect(repo.ApplyPatchCall.Receives.Patches).To(BeEmpty())
+				})
+			})
+		})
+	})
+
+	Describe("Continue", func() {
+		It("resumes from the recorded step", func() {
+			err := apply.Continue(patcher.State{Checkpoint: checkpoint, Change: 0, Step: 4})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.CheckoutCall.Receives.Ref).To(BeEmpty())
+			Expect(repo.CheckoutBranchCall.Receives.Name).To(BeEmpty())
+			Expect(repo.ApplyPatchCall.Receives.Patches).To(Equal([]string{"patch-2"}))
+			Expect(repo.AddSubmoduleCall.Receives.Submodules).To(BeEmpty())
+			Expect(repo.RemoveSubmoduleCall.Receives.Paths).To(BeEmpty())
+			Expect(repo.BumpSubmoduleCall.Receives.Submodules).To(Equal(map[string]string{
+				"src/some-other-path": "a-sha",
+				"src/some-path":       "some-other-sha",
+			}))
+			Expect(state.ClearCall.WasCalled).To(BeTrue())
+		})
+
+		Context("when the recorded step is a top-level patch", func() {
+			It("skips the patch finished by `git am --continue`", func() {
+				err := apply.Continue(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 0})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(repo.ApplyPatchCall.Receives.Patches).To(BeEmpty())
+				Expect(repo.BumpSubmoduleCall.Receives.Submodules).To(Equal(map[string]string{
+					"src/some-other-path": "a-sha",
+				}))
+			})
+
+			Context("when git am is still in progress", func() {
+				It("returns an error", func() {
+					repo.PatchInProgressCall.Returns.InProgress = true
+
+					err := apply.Continue(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 0})
+					Expect(err).To(MatchError("git am is still in progress in the repository. Please finish it with `git am --continue` before continuing"))
+
+					Expect(repo.BumpSubmoduleCall.Receives.Submodules).To(BeEmpty())
+					Expect(state.ClearCall.WasCalled).To(BeFalse())
+				})
+			})
+
+			Context("when checking for git am fails", func() {
+				It("returns an error", func() {
+					repo.PatchInProgressCall.Returns.Error = errors.New("meow")
+
+					err := apply.Continue(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 0})
+					Expect(err).To(MatchError("meow"))
+				})
+			})
+		})
+
+		Context("when HEAD has not moved since the patch was started", func() {
+			It("refuses to skip the patch", func() {
+				repo.HeadCall.Returns.SHAs = []string{"head-1"}
+
+				err := apply.Continue(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 0, Head: "head-1"})
+				Expect(err).To(MatchError("apply patch patch-2 was not applied: HEAD of the repository has not moved since the patch was started. Apply it with `git am`, or start over with --abort"))
+
+				Expect(repo.BumpSubmoduleCall.Receives.Submodules).To(BeEmpty())
+				Expect(state.ClearCall.WasCalled).To(BeFalse())
+			})
+		})
+
+		Context("when the recorded step is a submodule patch", func() {
+			It("checks the submodule and commits the patch applied there", func() {
+				repo.HeadCall.Returns.SHAs = []string{"patched-sub-head"}
+
+				err := apply.Continue(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 3, PatchPath: "src/some-other-sub/path", Head: "sub-head"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(repo.PatchInProgressCall.Receives.Path).To(Equal("src/some-other-sub/path"))
+				Expect(repo.HeadCall.Receives.Paths).To(Equal([]string{"src/some-other-sub/path"}))
+				Expect(repo.PatchSubmoduleCall.Receives.Paths).To(BeEmpty())
+				Expect(repo.CommitSubmodulePatchCall.Receives.Path).To(Equal("src/some-other-sub/path"))
+				Expect(repo.CommitSubmodulePatchCall.Receives.Patch).To(Equal("path/to/different.patch"))
+				Expect(state.ClearCall.WasCalled).To(BeTrue())
+			})
+
+			Context("when git am is still in progress in the submodule", func() {
+				It("returns an error", func() {
+					repo.PatchInProgressCall.Returns.InProgress = true
+
+					err := apply.Continue(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 3, PatchPath: "src/some-other-sub/path", Head: "sub-head"})
+					Expect(err).To(MatchError("git am is still in progress in submodule src/some-other-sub/path. Please finish it with `git am --continue` before continuing"))
+					Expect(repo.CommitSubmodulePatchCall.Receives.Path).To(BeEmpty())
+				})
+			})
+		})
+	})
+
+	Describe("Abort", func() {
+		It("aborts any patch, returns to the initial ref and deletes the branch", func() {
+			err := apply.Abort(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 0})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.AbortPatchCall.WasCalled).To(BeTrue())
+			Expect(repo.AbortPatchCall.Receives.Path).To(BeEmpty())
+			Expect(repo.CheckoutCall.Receives.Ref).To(Equal("abcde12345"))
+			Expect(repo.DeleteBranchCall.Receives.Names).To(Equal([]string{"1.9.2"}))
+			Expect(state.ClearCall.WasCalled).To(BeTrue())
+		})
+
+		It("aborts git am in the submodule it was started in", func() {
+			err := apply.Abort(patcher.State{Checkpoint: checkpoint, Change: 1, Step: 3, PatchPath: "src/some-other-sub/path"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.AbortPatchCall.Receives.Path).To(Equal("src/some-other-sub/path"))
+		})
+
+		Context("when deleting the branch fails", func() {
+			It("returns an error and keeps the progress", func() {
+				repo.DeleteBranchCall.Returns.Error = errors.New("meow")
+
+				err := apply.Abort(patcher.State{Checkpoint: checkpoint})
+				Expect(err).To(MatchError("meow"))
+				Expect(state.ClearCall.WasCalled).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("Checkpoints", func() {
+		var (
+			dryRunOut *bytes.Buffer
+			base      patcher.Checkpoint
+			extended  patcher.Checkpoint
+			hotfix    patcher.Checkpoint
+			otherRef  patcher.Checkpoint
+		)
+
+		BeforeEach(func() {
+			dryRunOut = bytes.NewBuffer([]byte{})
+
+			base = patcher.Checkpoint{
+				Changes:     []patcher.Changeset{{Patches: []string{"patch-1"}}},
+				CheckoutRef: "v1",
+				FinalBranch: "1.9.1",
+			}
+			hotfix = patcher.Checkpoint{
+				Changes:     []patcher.Changeset{{Patches: []string{"patch-1", "hotfix-1"}}},
+				CheckoutRef: "v1",
+				FinalBranch: "1.9.1+hotfix",
+			}
+			extended = patcher.Checkpoint{
+				Changes:     []patcher.Changeset{{Patches: []string{"patch-1"}}, {Patches: []string{"patch-2"}}},
+				CheckoutRef: "v1",
+				FinalBranch: "1.9.2",
+			}
+			otherRef = patcher.Checkpoint{
+				Changes:     []patcher.Changeset{{Patches: []string{"patch-1"}}, {Patches: []string{"patch-2"}}, {Patches: []string{"patch-3"}}},
+				CheckoutRef: "v2",
+				FinalBranch: "1.9.3",
+			}
+		})
+
+		It("builds on the branch of an earlier checkpoint that shares its changes", func() {
+			err := patcher.NewApply(patcher.NewDryRun(dryRunOut), nil).Checkpoints([]patcher.Checkpoint{base, hotfix, extended, otherRef})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dryRunOut.String()).To(Equal(`checkout v1
+create branch 1.9.1
+apply patch patch-1
+checkout v1
+create branch 1.9.1+hotfix
+apply patch patch-1
+apply patch hotfix-1
+checkout 1.9.1
+create branch 1.9.2
+apply patch patch-2
+checkout v2
+create branch 1.9.3
+apply patch patch-1
+apply patch patch-2
+apply patch patch-3
+`))
+		})
+
+		It("uses the commit messages of each checkpoint", func() {
+			base.CommitMessages = patcher.CommitMessages{Bump: "base bump"}
+			extended.CommitMessages = patcher.CommitMessages{Bump: "extended bump"}
+
+			err := patcher.NewApply(repo, state).Checkpoints([]patcher.Checkpoint{base, extended})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.SetCommitMessagesCall.Receives.Messages).To(Equal([]patcher.CommitMessages{
+				{Bump: "base bump"},
+				{Bump: "extended bump"},
+			}))
+		})
+
+		It("records the checkpoints that are still pending", func() {
+			err := patcher.NewApply(repo, state).Checkpoints([]patcher.Checkpoint{base, extended})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(state.SaveCall.Receives.States).To(Equal([]patcher.State{
+				{Pending: []patcher.Checkpoint{base, extended}},
+				{Checkpoint: base, Change: 0, Step: 0, Pending: []patcher.Checkpoint{extended}},
+				{Pending: []patcher.Checkpoint{extended}, Applied: []patcher.Checkpoint{base}},
+				{Checkpoint: extended, Change: 1, Step: 0, Applied: []patcher.Checkpoint{base}},
+			}))
+		})
+
+		Context("when checking out a later checkpoint fails", func() {
+			It("keeps the progress so the run can be continued or aborted", func() {
+				repo.CheckoutBranchCall.Returns.Error = errors.New("meow")
+
+				err := patcher.NewApply(repo, state).Checkpoints([]patcher.Checkpoint{base})
+				Expect(err).To(MatchError("meow"))
+
+				Expect(state.SaveCall.Receives.States).To(Equal([]patcher.State{
+					{Pending: []patcher.Checkpoint{base}},
+				}))
+				Expect(state.ClearCall.WasCalled).To(BeFalse())
+			})
+
+			It("checks out the checkpoint again when continuing", func() {
+				err := patcher.NewApply(patcher.NewDryRun(dryRunOut), nil).Continue(patcher.State{
+					Pending: []patcher.Checkpoint{extended},
+					Applied: []patcher.Checkpoint{base},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dryRunOut.String()).To(Equal(`checkout 1.9.1
+create branch 1.9.2
+apply patch patch-2
+`))
+			})
+		})
+
+		Context("when aborting with checkpoints applied", func() {
+			It("deletes the branches of the applied checkpoints as well", func() {
+				err := patcher.NewApply(repo, state).Abort(patcher.State{
+					Checkpoint: extended,
+					Change:     1,
+					Applied:    []patcher.Checkpoint{base, hotfix},
+					Pending:    []patcher.Checkpoint{otherRef},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(repo.CheckoutCall.Receives.Ref).To(Equal("v1"))
+				Expect(repo.DeleteBranchCall.Receives.Names).To(Equal([]string{"1.9.1", "1.9.1+hotfix", "1.9.2"}))
+				Expect(state.ClearCall.WasCalled).To(BeTrue())
+			})
+
+			It("deletes only the applied branches when the next checkpoint was not checked out", func() {
+				err := patcher.NewApply(repo, state).Abort(patcher.State{
+					Applied: []patcher.Checkpoint{base},
+					Pending: []patcher.Checkpoint{extended},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(repo.DeleteBranchCall.Receives.Names).To(Equal([]string{"1.9.1"}))
+			})
+		})
+
+		Context("when continuing with checkpoints pending", func() {
+			It("applies the pending checkpoints after the interrupted one", func() {
+				err := patcher.NewApply(patcher.NewDryRun(dryRunOut), nil).Continue(patcher.State{
+					Checkpoint: base,
+					Change:     1,
+					Pending:    []patcher.Checkpoint{extended},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dryRunOut.String()).To(Equal(`checkout 1.9.1
+create branch 1.9.2
+apply patch patch-2
+`))
+			})
+
+			It("builds on the checkpoints applied before the interruption", func() {
+				err := patcher.NewApply(patcher.NewDryRun(dryRunOut), nil).Continue(patcher.State{
+					Checkpoint: hotfix,
+					Change:     1,
+					Pending:    []patcher.Checkpoint{extended},
+					Applied:    []patcher.Checkpoint{base},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dryRunOut.String()).To(Equal(`checkout 1.9.1
+create branch 1.9.2
+apply patch patch-2
+`))
+			})
 		})
 	})
 })
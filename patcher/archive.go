@@ -0,0 +1,86 @@
+package patcher
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+func WriteArchive(w io.Writer, root string, files []string, prefix string, modTime time.Time) error {
+	sorted := append([]string{}, files...)
+	sort.Strings(sorted)
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range sorted {
+		err := writeArchiveEntry(tarWriter, root, file, prefix, modTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+func writeArchiveEntry(tarWriter *tar.Writer, root, file, prefix string, modTime time.Time) error {
+	fullPath := filepath.Join(root, filepath.FromSlash(file))
+
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    path.Join(prefix, file),
+		ModTime: modTime,
+		Mode:    0644,
+		Format:  tar.FormatPAX,
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		header.Typeflag = tar.TypeSymlink
+		header.Mode = 0777
+
+		header.Linkname, err = os.Readlink(fullPath)
+		if err != nil {
+			return err
+		}
+
+		return tarWriter.WriteHeader(header)
+	case info.IsDir():
+		return nil
+	}
+
+	if info.Mode()&0111 != 0 {
+		header.Mode = 0755
+	}
+
+	header.Typeflag = tar.TypeReg
+	header.Size = info.Size()
+
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tarWriter, f)
+	return err
+}
@@ -0,0 +1,119 @@
+package patcher_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pivotal-cf/knit/patcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WriteArchive", func() {
+	var (
+		root    string
+		modTime time.Time
+	)
+
+	type entry struct {
+		Name     string
+		Mode     int64
+		Linkname string
+		Contents string
+		ModTime  time.Time
+	}
+
+	readArchive := func(archive []byte) []entry {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+		Expect(err).NotTo(HaveOccurred())
+
+		var entries []entry
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := ioutil.ReadAll(tarReader)
+			Expect(err).NotTo(HaveOccurred())
+
+			entries = append(entries, entry{
+				Name:     header.Name,
+				Mode:     header.Mode,
+				Linkname: header.Linkname,
+				Contents: string(contents),
+				ModTime:  header.ModTime.UTC(),
+			})
+		}
+
+		return entries
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.MkdirAll(filepath.Join(root, "src", "sub"), 0755)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(root, "README.md"), []byte("readme"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(root, "src", "sub", "run.sh"), []byte("#!/bin/sh"), 0700)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Symlink("README.md", filepath.Join(root, "link"))
+		Expect(err).NotTo(HaveOccurred())
+
+		modTime = time.Date(2017, 3, 4, 5, 6, 7, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(root)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("writes the files in a stable order with normalized metadata", func() {
+		var archive bytes.Buffer
+		err := patcher.WriteArchive(&archive, root, []string{"src/sub/run.sh", "link", "README.md"}, "release-1.9.2", modTime)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(readArchive(archive.Bytes())).To(Equal([]entry{
+			{Name: "release-1.9.2/README.md", Mode: 0644, Contents: "readme", ModTime: modTime},
+			{Name: "release-1.9.2/link", Mode: 0777, Linkname: "README.md", ModTime: modTime},
+			{Name: "release-1.9.2/src/sub/run.sh", Mode: 0755, Contents: "#!/bin/sh", ModTime: modTime},
+		}))
+	})
+
+	It("produces identical bytes for the same files", func() {
+		var first, second bytes.Buffer
+		err := patcher.WriteArchive(&first, root, []string{"README.md", "link", "src/sub/run.sh"}, "", modTime)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Chtimes(filepath.Join(root, "README.md"), time.Now(), time.Now())
+		Expect(err).NotTo(HaveOccurred())
+
+		err = patcher.WriteArchive(&second, root, []string{"src/sub/run.sh", "README.md", "link"}, "", modTime)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(second.Bytes()).To(Equal(first.Bytes()))
+	})
+
+	Context("when a file does not exist", func() {
+		It("returns an error", func() {
+			var archive bytes.Buffer
+			err := patcher.WriteArchive(&archive, root, []string{"missing"}, "", modTime)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
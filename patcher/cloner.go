@@ -0,0 +1,247 @@
+package patcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var unsafeMirrorNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type Cloner struct {
+	SubmoduleJobs int
+	FetchDepth    int
+	Filter        string
+
+	runner   commandRunner
+	cacheDir string
+}
+
+func NewCloner(commandRunner commandRunner, cacheDir string) Cloner {
+	return Cloner{
+		runner:   commandRunner,
+		cacheDir: cacheDir,
+	}
+}
+
+func (c Cloner) Clone(url, path string) error {
+	var mirror string
+	if c.cacheDir != "" {
+		mirror = c.mirrorPath(url)
+
+		err := c.updateMirror(url, mirror)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := os.Stat(filepath.Join(path, ".git"))
+	switch {
+	case err == nil:
+		output, err := c.runner.CombinedOutput(Command{
+			Step: "Clone",
+			Args: []string{"remote", "get-url", "origin"},
+			Dir:  path,
+		})
+		if err != nil {
+			return fmt.Errorf("could not determine the origin of %s: %s", path, strings.TrimSpace(string(output)))
+		}
+
+		if origin := strings.TrimSpace(string(output)); origin != url {
+			return fmt.Errorf("%s is a clone of %s, not %s", path, origin, url)
+		}
+
+		err = c.runner.Run(Command{
+			Step: "Clone",
+			Args: []string{"fetch", "--prune", "--tags", "origin"},
+			Dir:  path,
+		})
+		if err != nil {
+			return fmt.Errorf("could not fetch %s into %s: %s", url, path, err)
+		}
+	case os.IsNotExist(err):
+		args := []string{"clone"}
+		if mirror != "" {
+			args = append(args, "--reference", mirror)
+		}
+
+		err = c.runner.Run(Command{
+			Step: "Clone",
+			Args: append(args, url, path),
+		})
+		if err != nil {
+			return fmt.Errorf("could not clone %s into %s: %s", url, path, err)
+		}
+	default:
+		return err
+	}
+
+	if mirror != "" {
+		err = c.mirrorSubmodules(path, url, mirror)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.runner.Run(Command{
+		Step: "Clone",
+		Args: append([]string{"submodule", "update", "--init", "--recursive"}, submoduleFetchOptions(c.SubmoduleJobs, c.FetchDepth, c.Filter)...),
+		Dir:  path,
+	})
+	if err != nil {
+		return fmt.Errorf("could not update the submodules of %s: %s", path, err)
+	}
+
+	return nil
+}
+
+func (c Cloner) mirrorPath(url string) string {
+	name := strings.Trim(unsafeMirrorNameRegexp.ReplaceAllString(url, "_"), "_")
+	return filepath.Join(c.cacheDir, strings.TrimSuffix(name, ".git")+".git")
+}
+
+func (c Cloner) mirrorSubmodules(path, url, mirror string) error {
+	for _, config := range [][]string{
+		{"submodule.alternateLocation", "superproject"},
+		{"submodule.alternateErrorStrategy", "info"},
+	} {
+		output, err := c.runner.CombinedOutput(Command{
+			Step: "Clone",
+			Args: append([]string{"config"}, config...),
+			Dir:  path,
+		})
+		if err != nil {
+			return fmt.Errorf("could not configure %s: %s", path, strings.TrimSpace(string(output)))
+		}
+	}
+
+	return c.mirrorNestedSubmodules(path, "HEAD", url, mirror)
+}
+
+func (c Cloner) mirrorNestedSubmodules(dir, commit, url, mirror string) error {
+	output, err := c.runner.CombinedOutput(Command{
+		Step: "Clone",
+		Args: []string{"config", "--blob", fmt.Sprintf("%s:.gitmodules", commit), "--get-regexp", `^submodule\..*\.(path|url)$`},
+		Dir:  dir,
+	})
+	if err != nil {
+		return nil
+	}
+
+	paths := map[string]string{}
+	urls := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		key := strings.TrimPrefix(fields[0], "submodule.")
+		switch {
+		case strings.HasSuffix(key, ".path"):
+			paths[strings.TrimSuffix(key, ".path")] = fields[1]
+		case strings.HasSuffix(key, ".url"):
+			urls[strings.TrimSuffix(key, ".url")] = fields[1]
+		}
+	}
+
+	var names []string
+	for name := range urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		submoduleURL := resolveSubmoduleURL(url, urls[name])
+		submoduleMirror := filepath.Join(mirror, "modules", name)
+
+		err = c.updateMirror(submoduleURL, submoduleMirror)
+		if err != nil {
+			return err
+		}
+
+		sha, ok := c.gitlink(dir, commit, paths[name])
+		if !ok {
+			continue
+		}
+
+		err = c.mirrorNestedSubmodules(submoduleMirror, sha, submoduleURL, submoduleMirror)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c Cloner) gitlink(dir, commit, path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+
+	output, err := c.runner.CombinedOutput(Command{
+		Step: "Clone",
+		Args: []string{"ls-tree", "-z", commit, "--", path},
+		Dir:  dir,
+	})
+	if err != nil {
+		return "", false
+	}
+
+	info := strings.Fields(strings.SplitN(string(output), "\t", 2)[0])
+	if len(info) != 3 || info[0] != gitlinkMode {
+		return "", false
+	}
+
+	return info[2], true
+}
+
+func resolveSubmoduleURL(parent, url string) string {
+	if !strings.HasPrefix(url, "./") && !strings.HasPrefix(url, "../") {
+		return url
+	}
+
+	base := strings.TrimSuffix(parent, "/")
+	for {
+		switch {
+		case strings.HasPrefix(url, "./"):
+			url = strings.TrimPrefix(url, "./")
+		case strings.HasPrefix(url, "../"):
+			url = strings.TrimPrefix(url, "../")
+			if i := strings.LastIndexAny(base, "/:"); i >= 0 {
+				base = base[:i]
+			}
+		default:
+			return base + "/" + url
+		}
+	}
+}
+
+func (c Cloner) updateMirror(url, mirror string) error {
+	_, err := os.Stat(mirror)
+	if err == nil {
+		err = c.runner.Run(Command{
+			Step: "Clone",
+			Args: []string{"fetch", "--prune"},
+			Dir:  mirror,
+		})
+		if err != nil {
+			return fmt.Errorf("could not update the mirror of %s in %s: %s", url, mirror, err)
+		}
+
+		return nil
+	}
+
+	err = c.runner.Run(Command{
+		Step: "Clone",
+		Args: []string{"clone", "--mirror", url, mirror},
+	})
+	if err != nil {
+		return fmt.Errorf("could not mirror %s into %s: %s", url, mirror, err)
+	}
+
+	return nil
+}
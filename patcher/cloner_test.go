@@ -0,0 +1,204 @@
+package patcher_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pivotal-cf/knit/patcher"
+	"github.com/pivotal-cf/knit/patcher/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cloner", func() {
+	var (
+		tmpDir      string
+		origin      string
+		submodule   string
+		cacheDir    string
+		clonePath   string
+		cloner      patcher.Cloner
+		environment []string
+	)
+
+	git := func(dir string, args ...string) string {
+		command := exec.Command("git", args...)
+		command.Dir = dir
+		output, err := command.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("Error: %s", output))
+
+		return strings.TrimSpace(string(output))
+	}
+
+	commit := func(dir, file, contents string) {
+		err := ioutil.WriteFile(filepath.Join(dir, file), []byte(contents), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		git(dir, "add", "-A")
+		git(dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "change "+file)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		environment = os.Environ()
+		os.Setenv("GIT_CONFIG_COUNT", "1")
+		os.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+		os.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+		submodule = filepath.Join(tmpDir, "submodule")
+		origin = filepath.Join(tmpDir, "origin")
+		cacheDir = filepath.Join(tmpDir, "cache")
+		clonePath = filepath.Join(tmpDir, "clone")
+
+		for _, dir := range []string{submodule, origin} {
+			err = os.Mkdir(dir, 0755)
+			Expect(err).NotTo(HaveOccurred())
+			git(dir, "init")
+		}
+
+		commit(submodule, "sub.txt", "sub")
+		commit(origin, "file.txt", "one")
+		git(origin, "submodule", "add", submodule, "src/sub")
+		git(origin, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "add submodule")
+
+		runner, err := patcher.NewCommandRunner("git", true)
+		Expect(err).NotTo(HaveOccurred())
+
+		cloner = patcher.NewCloner(runner, cacheDir)
+	})
+
+	AfterEach(func() {
+		os.Clearenv()
+		for _, variable := range environment {
+			parts := strings.SplitN(variable, "=", 2)
+			os.Setenv(parts[0], parts[1])
+		}
+
+		err := os.RemoveAll(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("clones the repository and its submodules", func() {
+		err := cloner.Clone(origin, clonePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := ioutil.ReadFile(filepath.Join(clonePath, "src", "sub", "sub.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("sub"))
+	})
+
+	It("borrows the objects of the repository and its submodules from mirrors in the cache", func() {
+		err := cloner.Clone(origin, clonePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		mirrors, err := filepath.Glob(filepath.Join(cacheDir, "*.git"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mirrors).To(HaveLen(1))
+
+		alternates, err := ioutil.ReadFile(filepath.Join(clonePath, ".git", "objects", "info", "alternates"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(alternates)).To(ContainSubstring(filepath.Join(mirrors[0], "objects")))
+
+		alternates, err = ioutil.ReadFile(filepath.Join(clonePath, ".git", "modules", "src", "sub", "objects", "info", "alternates"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(alternates)).To(ContainSubstring(filepath.Join(mirrors[0], "modules", "src", "sub")))
+	})
+
+	Context("when a submodule has submodules of its own", func() {
+		var nested string
+
+		BeforeEach(func() {
+			nested = filepath.Join(tmpDir, "nested")
+			err := os.Mkdir(nested, 0755)
+			Expect(err).NotTo(HaveOccurred())
+			git(nested, "init")
+			commit(nested, "nested.txt", "nested")
+
+			git(submodule, "submodule", "add", nested, "src/nested")
+			git(submodule, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "add nested submodule")
+
+			git(filepath.Join(origin, "src", "sub"), "pull", "origin", "HEAD")
+			git(origin, "add", "src/sub")
+			git(origin, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "bump submodule")
+		})
+
+		It("borrows the objects of the nested submodules from mirrors in the cache as well", func() {
+			err := cloner.Clone(origin, clonePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := ioutil.ReadFile(filepath.Join(clonePath, "src", "sub", "src", "nested", "nested.txt"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("nested"))
+
+			mirrors, err := filepath.Glob(filepath.Join(cacheDir, "*.git"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mirrors).To(HaveLen(1))
+
+			nestedMirror := filepath.Join(mirrors[0], "modules", "src", "sub", "modules", "src", "nested")
+			Expect(nestedMirror).To(BeADirectory())
+
+			alternates, err := ioutil.ReadFile(filepath.Join(clonePath, ".git", "modules", "src", "sub", "modules", "src", "nested", "objects", "info", "alternates"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(alternates)).To(ContainSubstring(nestedMirror))
+		})
+	})
+
+	Context("when the repository has already been cloned", func() {
+		BeforeEach(func() {
+			err := cloner.Clone(origin, clonePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			commit(origin, "file.txt", "two")
+			git(origin, "tag", "v2")
+		})
+
+		It("fetches the new commits and tags", func() {
+			err := cloner.Clone(origin, clonePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(git(clonePath, "rev-parse", "v2")).To(Equal(git(origin, "rev-parse", "HEAD")))
+		})
+
+		It("returns an error when the clone is of another repository", func() {
+			err := cloner.Clone(submodule, clonePath)
+			Expect(err).To(MatchError(fmt.Sprintf("%s is a clone of %s, not %s", clonePath, origin, submodule)))
+		})
+	})
+
+	Context("when submodule fetch options are set", func() {
+		It("passes them to the submodule update", func() {
+			runner := &fakes.CommandRunner{}
+			cloner = patcher.NewCloner(runner, "")
+			cloner.SubmoduleJobs = 8
+			cloner.FetchDepth = 1
+			cloner.Filter = "blob:none"
+
+			err := cloner.Clone(origin, clonePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(HaveLen(2))
+			Expect(runner.RunCall.Receives.Commands[1]).To(Equal(patcher.Command{
+				Step: "Clone",
+				Args: []string{"submodule", "update", "--init", "--recursive", "--jobs=8", "--depth=1", "--filter=blob:none"},
+				Dir:  clonePath,
+			}))
+		})
+	})
+
+	Context("when the repository cannot be cloned", func() {
+		It("returns an error", func() {
+			missing := filepath.Join(tmpDir, "missing")
+
+			err := cloner.Clone(missing, clonePath)
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("could not mirror %s", missing))))
+		})
+	})
+})
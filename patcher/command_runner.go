@@ -1,20 +1,27 @@
 package patcher
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 )
 
 type Command struct {
+	Step string
 	Args []string
 	Dir  string
+	Env  []string
 }
 
 type CommandRunner struct {
 	Executable string
 	Stdout     io.Writer
 	Stderr     io.Writer
+	EventLog   *EventLog
 }
 
 func NewCommandRunner(executable string, quiet bool) (CommandRunner, error) {
@@ -38,29 +45,99 @@ func (r CommandRunner) CombinedOutput(command Command) ([]byte, error) {
 		Path: r.Executable,
 		Args: append([]string{r.Executable}, command.Args...),
 		Dir:  command.Dir,
+		Env:  environment(command),
 	}
 
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+
+	logErr := r.EventLog.record(command, time.Since(start), err, output)
 	if err != nil {
 		return output, err
 	}
 
+	if logErr != nil {
+		return output, logErr
+	}
+
 	return output, nil
 }
 
+func (r CommandRunner) Output(command Command) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := &exec.Cmd{
+		Path:   r.Executable,
+		Args:   append([]string{r.Executable}, command.Args...),
+		Dir:    command.Dir,
+		Env:    environment(command),
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+
+	output := append(append([]byte{}, stdout.Bytes()...), stderr.Bytes()...)
+	logErr := r.EventLog.record(command, time.Since(start), err, output)
+	if err != nil {
+		if stderr.Len() > 0 {
+			return stdout.Bytes(), errors.New(strings.TrimSpace(stderr.String()))
+		}
+
+		return stdout.Bytes(), err
+	}
+
+	if logErr != nil {
+		return stdout.Bytes(), logErr
+	}
+
+	return stdout.Bytes(), nil
+}
+
 func (r CommandRunner) Run(command Command) error {
 	cmd := &exec.Cmd{
 		Path:   r.Executable,
 		Args:   append([]string{r.Executable}, command.Args...),
 		Dir:    command.Dir,
+		Env:    environment(command),
 		Stdout: r.Stdout,
 		Stderr: r.Stderr,
 	}
 
+	var output bytes.Buffer
+	if r.EventLog != nil {
+		captured := &syncWriter{w: &output}
+		cmd.Stdout = teeWriter(r.Stdout, captured)
+		cmd.Stderr = teeWriter(r.Stderr, captured)
+	}
+
+	start := time.Now()
 	err := cmd.Run()
+
+	logErr := r.EventLog.record(command, time.Since(start), err, output.Bytes())
 	if err != nil {
 		return err
 	}
 
+	if logErr != nil {
+		return logErr
+	}
+
 	return nil
 }
+
+func environment(command Command) []string {
+	if len(command.Env) == 0 {
+		return nil
+	}
+
+	return append(os.Environ(), command.Env...)
+}
+
+func teeWriter(w io.Writer, captured io.Writer) io.Writer {
+	if w == nil {
+		return captured
+	}
+
+	return io.MultiWriter(w, captured)
+}
@@ -2,9 +2,12 @@ package patcher_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pivotal-cf/knit/patcher"
 
@@ -55,6 +58,20 @@ var _ = Describe("CommandRunner", func() {
 			Expect(runner.Stderr).To(Equal(bytes.NewBuffer([]byte{})))
 		})
 
+		It("adds the given environment to the environment of the command", func() {
+			runner, err = patcher.NewCommandRunner("sh", true)
+			Expect(err).NotTo(HaveOccurred())
+			runner.Stderr = bytes.NewBuffer([]byte{})
+			runner.Stdout = bytes.NewBuffer([]byte{})
+
+			err = runner.Run(patcher.Command{
+				Args: []string{"-c", "echo $KNIT_TEST_VALUE $HOME"},
+				Env:  []string{"KNIT_TEST_VALUE=banana"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runner.Stdout).To(Equal(bytes.NewBuffer([]byte(fmt.Sprintf("banana %s\n", os.Getenv("HOME"))))))
+		})
+
 		It("includes stderr output", func() {
 			runner, err = patcher.NewCommandRunner("curl", true)
 			Expect(err).NotTo(HaveOccurred())
@@ -94,6 +111,138 @@ var _ = Describe("CommandRunner", func() {
 		})
 	})
 
+	Describe("EventLog", func() {
+		var (
+			runner patcher.CommandRunner
+			events *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			var err error
+			runner, err = patcher.NewCommandRunner("sh", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			events = bytes.NewBuffer([]byte{})
+			runner.EventLog = patcher.NewEventLog(events)
+		})
+
+		decode := func() []patcher.Event {
+			var decoded []patcher.Event
+			for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+				var event patcher.Event
+				Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+				decoded = append(decoded, event)
+			}
+
+			return decoded
+		}
+
+		It("records a JSON line for every command", func() {
+			err := runner.Run(patcher.Command{
+				Step: "Checkout",
+				Args: []string{"-c", "echo out; echo err >&2"},
+				Dir:  "/",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = runner.CombinedOutput(patcher.Command{
+				Step: "GitPath",
+				Args: []string{"-c", "echo combined"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			decoded := decode()
+			Expect(decoded).To(HaveLen(2))
+
+			Expect(decoded[0].Step).To(Equal("Checkout"))
+			Expect(decoded[0].Args).To(Equal([]string{"-c", "echo out; echo err >&2"}))
+			Expect(decoded[0].Dir).To(Equal("/"))
+			Expect(decoded[0].ExitCode).To(Equal(0))
+			Expect(decoded[0].Output).To(ContainSubstring("out\n"))
+			Expect(decoded[0].Output).To(ContainSubstring("err\n"))
+			Expect(decoded[0].Duration).To(BeNumerically(">", 0))
+
+			Expect(decoded[1].Step).To(Equal("GitPath"))
+			Expect(decoded[1].Output).To(Equal("combined\n"))
+		})
+
+		It("still streams the output", func() {
+			stdout := bytes.NewBuffer([]byte{})
+			runner.Stdout = stdout
+
+			err := runner.Run(patcher.Command{
+				Args: []string{"-c", "echo banana"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stdout.String()).To(Equal("banana\n"))
+			Expect(decode()[0].Output).To(Equal("banana\n"))
+		})
+
+		It("records the step of the change being applied", func() {
+			runner.EventLog.StartStep("1.9.2", 1, "apply patch some.patch")
+			err := runner.Run(patcher.Command{
+				Step: "ApplyPatch",
+				Args: []string{"-c", "true"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			runner.EventLog.EndStep()
+			err = runner.Run(patcher.Command{
+				Step: "DeleteBranch",
+				Args: []string{"-c", "true"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			change := 1
+			decoded := decode()
+			Expect(decoded[0].Branch).To(Equal("1.9.2"))
+			Expect(decoded[0].Change).To(Equal(&change))
+			Expect(decoded[0].Description).To(Equal("apply patch some.patch"))
+
+			Expect(decoded[1].Branch).To(BeEmpty())
+			Expect(decoded[1].Change).To(BeNil())
+			Expect(decoded[1].Description).To(BeEmpty())
+		})
+
+		It("records the exit code of a failed command", func() {
+			err := runner.Run(patcher.Command{
+				Step: "ApplyPatch",
+				Args: []string{"-c", "echo conflict; exit 3"},
+			})
+			Expect(err).To(MatchError("exit status 3"))
+
+			decoded := decode()
+			Expect(decoded[0].ExitCode).To(Equal(3))
+			Expect(decoded[0].Output).To(Equal("conflict\n"))
+		})
+	})
+
+	Describe("Output", func() {
+		It("returns stdout without stderr", func() {
+			runner, err := patcher.NewCommandRunner("sh", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			output, err := runner.Output(patcher.Command{
+				Args: []string{"-c", "echo out; echo err >&2"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(Equal([]byte("out\n")))
+		})
+
+		Context("when the command fails", func() {
+			It("returns stderr as the error", func() {
+				runner, err := patcher.NewCommandRunner("sh", true)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = runner.Output(patcher.Command{
+					Args: []string{"-c", "echo out; echo something broke >&2; exit 1"},
+				})
+				Expect(err).To(MatchError("something broke"))
+			})
+		})
+	})
+
 	Describe("CombinedOutput", func() {
 		var (
 			runner patcher.CommandRunner
@@ -0,0 +1,98 @@
+package patcher
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+type CommitMessages struct {
+	Bump           string `yaml:"bump" json:"bump,omitempty"`
+	Addition       string `yaml:"addition" json:"addition,omitempty"`
+	Removal        string `yaml:"removal" json:"removal,omitempty"`
+	SubmodulePatch string `yaml:"submodule_patch" json:"submodule_patch,omitempty"`
+	Fork           string `yaml:"fork" json:"fork,omitempty"`
+}
+
+type CommitMessageData struct {
+	Path      string
+	OldSHA    string
+	NewSHA    string
+	Version   string
+	PatchFile string
+	URL       string
+}
+
+func (m CommitMessages) Merge(overrides CommitMessages) CommitMessages {
+	if overrides.Bump != "" {
+		m.Bump = overrides.Bump
+	}
+
+	if overrides.Addition != "" {
+		m.Addition = overrides.Addition
+	}
+
+	if overrides.Removal != "" {
+		m.Removal = overrides.Removal
+	}
+
+	if overrides.SubmodulePatch != "" {
+		m.SubmodulePatch = overrides.SubmodulePatch
+	}
+
+	if overrides.Fork != "" {
+		m.Fork = overrides.Fork
+	}
+
+	return m
+}
+
+func (m CommitMessages) Validate() error {
+	templates := []struct {
+		name string
+		text string
+	}{
+		{"bump", m.Bump},
+		{"addition", m.Addition},
+		{"removal", m.Removal},
+		{"submodule_patch", m.SubmodulePatch},
+		{"fork", m.Fork},
+	}
+
+	for _, t := range templates {
+		if t.text == "" {
+			continue
+		}
+
+		_, err := renderCommitMessage(t.name, t.text, CommitMessageData{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func renderCommitMessage(name, text string, data CommitMessageData) (string, error) {
+	tmpl, err := parseCommitMessage(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var message bytes.Buffer
+	err = tmpl.Execute(&message, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render %s commit message: %s", name, err)
+	}
+
+	return message.String(), nil
+}
+
+func parseCommitMessage(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s commit message template: %s", name, err)
+	}
+
+	return tmpl, nil
+}
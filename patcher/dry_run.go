@@ -0,0 +1,81 @@
+package patcher
+
+import (
+	"fmt"
+	"io"
+)
+
+type DryRun struct {
+	out io.Writer
+}
+
+func NewDryRun(out io.Writer) DryRun {
+	return DryRun{
+		out: out,
+	}
+}
+
+func (d DryRun) SetCommitMessages(messages CommitMessages) {}
+
+func (d DryRun) Checkout(checkoutRef string) error {
+	return d.record("checkout %s", checkoutRef)
+}
+
+func (d DryRun) CheckoutBranch(name string) error {
+	return d.record("create branch %s", name)
+}
+
+func (d DryRun) DeleteBranch(name string) error {
+	return d.record("delete branch %s", name)
+}
+
+func (d DryRun) ApplyPatch(patch string) error {
+	return d.record("apply patch %s", patch)
+}
+
+func (d DryRun) PatchInProgress(path string) (bool, error) {
+	return false, nil
+}
+
+func (d DryRun) AbortPatch(path string) error {
+	return d.record("abort patch")
+}
+
+func (d DryRun) Head(path string) (string, error) {
+	return "", nil
+}
+
+func (d DryRun) AddSubmodule(path, url, ref, branch string) error {
+	if branch != "" {
+		return d.record("add submodule %s from %s at %s (branch %s)", path, url, ref, branch)
+	}
+
+	return d.record("add submodule %s from %s at %s", path, url, ref)
+}
+
+func (d DryRun) RemoveSubmodule(path string) error {
+	return d.record("remove submodule %s", path)
+}
+
+func (d DryRun) FetchSubmodules(bumps map[string]string) {}
+
+func (d DryRun) BumpSubmodule(path, sha string) error {
+	return d.record("bump submodule %s to %s", path, sha)
+}
+
+func (d DryRun) PatchSubmodule(path string, patch string) error {
+	return d.record("patch submodule %s with %s", path, patch)
+}
+
+func (d DryRun) CommitSubmodulePatch(path string, patch string) error {
+	return d.record("commit patch of submodule %s with %s", path, patch)
+}
+
+func (d DryRun) ForkSubmodule(path, url string) error {
+	return d.record("fork submodule %s to %s", path, url)
+}
+
+func (d DryRun) record(format string, args ...interface{}) error {
+	_, err := fmt.Fprintf(d.out, format+"\n", args...)
+	return err
+}
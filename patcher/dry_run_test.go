@@ -0,0 +1,72 @@
+package patcher_test
+
+import (
+	"bytes"
+
+	"github.com/pivotal-cf/knit/patcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DryRun", func() {
+	var (
+		out    *bytes.Buffer
+		dryRun patcher.DryRun
+	)
+
+	BeforeEach(func() {
+		out = bytes.NewBuffer([]byte{})
+		dryRun = patcher.NewDryRun(out)
+	})
+
+	It("prints every operation in the order apply executes them", func() {
+		checkpoint := patcher.Checkpoint{
+			Changes: []patcher.Changeset{
+				{
+					Patches: []string{"patch-1", "patch-2"},
+					Bumps: map[string]string{
+						"src/some-path":  "some-sha",
+						"src/other-path": "other-sha",
+					},
+					SubmodulePatches: map[string][]string{
+						"src/sub/path": []string{"path/to/other.patch"},
+					},
+					SubmoduleAdditions: map[string]patcher.SubmoduleAddition{
+						"src/new/sub": patcher.SubmoduleAddition{
+							URL:    "fake-url",
+							Ref:    "fake-ref",
+							Branch: "fake-branch",
+						},
+						"src/another/sub": patcher.SubmoduleAddition{
+							URL: "another-url",
+							Ref: "another-ref",
+						},
+					},
+					SubmoduleRemovals: []string{"src/some-old-submodule"},
+				},
+				{
+					Patches: []string{"patch-3"},
+				},
+			},
+			CheckoutRef: "abcde12345",
+			FinalBranch: "1.9.2",
+		}
+
+		err := patcher.NewApply(dryRun, nil).Checkpoint(checkpoint)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(out.String()).To(Equal(`checkout abcde12345
+create branch 1.9.2
+apply patch patch-1
+apply patch patch-2
+add submodule src/another/sub from another-url at another-ref
+add submodule src/new/sub from fake-url at fake-ref (branch fake-branch)
+remove submodule src/some-old-submodule
+bump submodule src/other-path to other-sha
+bump submodule src/some-path to some-sha
+patch submodule src/sub/path with path/to/other.patch
+apply patch patch-3
+`))
+	})
+})
@@ -0,0 +1,125 @@
+package patcher
+
+import (
+	"encoding/json"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+type Event struct {
+	Branch      string   `json:"branch,omitempty"`
+	Change      *int     `json:"change,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Step        string   `json:"step"`
+	Args        []string `json:"args"`
+	Dir         string   `json:"dir"`
+	Duration    float64  `json:"duration"`
+	ExitCode    int      `json:"exit_code"`
+	Output      string   `json:"output"`
+}
+
+type EventLog struct {
+	mutex  *sync.Mutex
+	writer io.Writer
+	step   *eventStep
+}
+
+type eventStep struct {
+	branch      string
+	change      int
+	description string
+}
+
+func NewEventLog(writer io.Writer) *EventLog {
+	return &EventLog{
+		mutex:  &sync.Mutex{},
+		writer: writer,
+	}
+}
+
+func (l *EventLog) Record(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_, err = l.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (l *EventLog) StartStep(branch string, change int, description string) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.step = &eventStep{
+		branch:      branch,
+		change:      change,
+		description: description,
+	}
+}
+
+func (l *EventLog) EndStep() {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.step = nil
+}
+
+func (l *EventLog) record(command Command, duration time.Duration, err error, output []byte) error {
+	if l == nil {
+		return nil
+	}
+
+	exitCode := 0
+	if err != nil {
+		exitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+
+	event := Event{
+		Step:     command.Step,
+		Args:     command.Args,
+		Dir:      command.Dir,
+		Duration: duration.Seconds(),
+		ExitCode: exitCode,
+		Output:   string(output),
+	}
+
+	l.mutex.Lock()
+	if l.step != nil {
+		change := l.step.change
+		event.Branch = l.step.branch
+		event.Change = &change
+		event.Description = l.step.description
+	}
+	l.mutex.Unlock()
+
+	return l.Record(event)
+}
+
+type syncWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.w.Write(p)
+}
@@ -1,8 +1,14 @@
 package fakes
 
-import "github.com/pivotal-cf/knit/patcher"
+import (
+	"sync"
+
+	"github.com/pivotal-cf/knit/patcher"
+)
 
 type CommandRunner struct {
+	mutex sync.Mutex
+
 	RunCall struct {
 		Count    int
 		Stub     func(patcher.Command) error
@@ -24,32 +30,68 @@ type CommandRunner struct {
 			Errors  []error
 		}
 	}
+	OutputCall struct {
+		Count    int
+		Stub     func(patcher.Command) ([]byte, error)
+		Receives struct {
+			Commands []patcher.Command
+		}
+		Returns struct {
+			Outputs [][]byte
+			Errors  []error
+		}
+	}
 }
 
 func (r *CommandRunner) Run(command patcher.Command) error {
+	r.mutex.Lock()
 	r.RunCall.Receives.Commands = append(r.RunCall.Receives.Commands, command)
 	r.RunCall.Count = r.RunCall.Count + 1
+	count := r.RunCall.Count
+	stub := r.RunCall.Stub
+	r.mutex.Unlock()
 
-	if r.RunCall.Stub != nil {
-		return r.RunCall.Stub(command)
+	if stub != nil {
+		return stub(command)
 	}
-	if len(r.RunCall.Returns.Errors) <= r.RunCall.Count-1 {
+	if len(r.RunCall.Returns.Errors) <= count-1 {
 		return nil
 	}
-	return r.RunCall.Returns.Errors[r.RunCall.Count-1]
+	return r.RunCall.Returns.Errors[count-1]
 }
 
 func (r *CommandRunner) CombinedOutput(command patcher.Command) ([]byte, error) {
+	r.mutex.Lock()
 	r.CombinedOutputCall.Receives.Commands = append(r.CombinedOutputCall.Receives.Commands, command)
 	r.CombinedOutputCall.Count = r.CombinedOutputCall.Count + 1
+	index := r.CombinedOutputCall.Count - 1
+	stub := r.CombinedOutputCall.Stub
+	r.mutex.Unlock()
 
-	if r.CombinedOutputCall.Stub != nil {
-		return r.CombinedOutputCall.Stub(command)
+	if stub != nil {
+		return stub(command)
 	}
-	index := r.CombinedOutputCall.Count - 1
 	if len(r.CombinedOutputCall.Returns.Errors) <= index {
 		return []byte{}, nil
 	}
 
 	return r.CombinedOutputCall.Returns.Outputs[index], r.CombinedOutputCall.Returns.Errors[index]
 }
+
+func (r *CommandRunner) Output(command patcher.Command) ([]byte, error) {
+	r.mutex.Lock()
+	r.OutputCall.Receives.Commands = append(r.OutputCall.Receives.Commands, command)
+	r.OutputCall.Count = r.OutputCall.Count + 1
+	index := r.OutputCall.Count - 1
+	stub := r.OutputCall.Stub
+	r.mutex.Unlock()
+
+	if stub != nil {
+		return stub(command)
+	}
+	if len(r.OutputCall.Returns.Errors) <= index {
+		return []byte{}, nil
+	}
+
+	return r.OutputCall.Returns.Outputs[index], r.OutputCall.Returns.Errors[index]
+}
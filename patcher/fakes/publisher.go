@@ -0,0 +1,85 @@
+package fakes
+
+import "github.com/pivotal-cf/knit/patcher"
+
+type Publisher struct {
+	Calls []string
+
+	TagCall struct {
+		Receives struct {
+			Names []string
+			Refs  []string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+
+	PushCall struct {
+		Receives struct {
+			Remote   string
+			Refspecs []string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+
+	SubmoduleCommitsCall struct {
+		Receives struct {
+			Paths []string
+		}
+		Returns struct {
+			SHAs    map[string]string
+			Commits map[string][]patcher.SubmoduleCommit
+			Error   error
+		}
+	}
+
+	PushSubmoduleCall struct {
+		Receives struct {
+			Paths    []string
+			Remotes  []string
+			Refspecs []string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+}
+
+func (p *Publisher) Tag(name, ref string) error {
+	p.Calls = append(p.Calls, "Tag")
+	p.TagCall.Receives.Names = append(p.TagCall.Receives.Names, name)
+	p.TagCall.Receives.Refs = append(p.TagCall.Receives.Refs, ref)
+
+	return p.TagCall.Returns.Error
+}
+
+func (p *Publisher) Push(remote string, refspecs []string) error {
+	p.Calls = append(p.Calls, "Push")
+	p.PushCall.Receives.Remote = remote
+	p.PushCall.Receives.Refspecs = refspecs
+
+	return p.PushCall.Returns.Error
+}
+
+func (p *Publisher) SubmoduleCommits(ref, path string) ([]patcher.SubmoduleCommit, error) {
+	p.Calls = append(p.Calls, "SubmoduleCommits")
+	p.SubmoduleCommitsCall.Receives.Paths = append(p.SubmoduleCommitsCall.Receives.Paths, path)
+
+	if commits, ok := p.SubmoduleCommitsCall.Returns.Commits[path]; ok {
+		return commits, p.SubmoduleCommitsCall.Returns.Error
+	}
+
+	return []patcher.SubmoduleCommit{{Path: path, SHA: p.SubmoduleCommitsCall.Returns.SHAs[path]}}, p.SubmoduleCommitsCall.Returns.Error
+}
+
+func (p *Publisher) PushSubmodule(path, remote, refspec string) error {
+	p.Calls = append(p.Calls, "PushSubmodule")
+	p.PushSubmoduleCall.Receives.Paths = append(p.PushSubmoduleCall.Receives.Paths, path)
+	p.PushSubmoduleCall.Receives.Remotes = append(p.PushSubmoduleCall.Receives.Remotes, remote)
+	p.PushSubmoduleCall.Receives.Refspecs = append(p.PushSubmoduleCall.Receives.Refspecs, refspec)
+
+	return p.PushSubmoduleCall.Returns.Error
+}
@@ -3,6 +3,12 @@ package fakes
 import "github.com/pivotal-cf/knit/patcher"
 
 type Repository struct {
+	SetCommitMessagesCall struct {
+		Receives struct {
+			Messages []patcher.CommitMessages
+		}
+	}
+
 	CheckoutCall struct {
 		Receives struct {
 			Ref string
@@ -39,6 +45,12 @@ type Repository struct {
 		}
 	}
 
+	FetchSubmodulesCall struct {
+		Receives struct {
+			Bumps []map[string]string
+		}
+	}
+
 	BumpSubmoduleCall struct {
 		Receives struct {
 			Submodules map[string]string
@@ -58,6 +70,15 @@ type Repository struct {
 		}
 	}
 
+	ForkSubmoduleCall struct {
+		Receives struct {
+			Forks map[string]string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+
 	CheckoutBranchCall struct {
 		Receives struct {
 			Name string
@@ -66,6 +87,60 @@ type Repository struct {
 			Error error
 		}
 	}
+
+	DeleteBranchCall struct {
+		Receives struct {
+			Names []string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+
+	CommitSubmodulePatchCall struct {
+		Receives struct {
+			Path  string
+			Patch string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+
+	PatchInProgressCall struct {
+		Receives struct {
+			Path string
+		}
+		Returns struct {
+			InProgress bool
+			Error      error
+		}
+	}
+
+	AbortPatchCall struct {
+		WasCalled bool
+		Receives  struct {
+			Path string
+		}
+		Returns struct {
+			Error error
+		}
+	}
+
+	HeadCall struct {
+		CallCount int
+		Receives  struct {
+			Paths []string
+		}
+		Returns struct {
+			SHAs  []string
+			Error error
+		}
+	}
+}
+
+func (r *Repository) SetCommitMessages(messages patcher.CommitMessages) {
+	r.SetCommitMessagesCall.Receives.Messages = append(r.SetCommitMessagesCall.Receives.Messages, messages)
 }
 
 func (r *Repository) Checkout(checkoutRef string) error {
@@ -99,6 +174,10 @@ func (r *Repository) RemoveSubmodule(path string) error {
 	return r.RemoveSubmoduleCall.Returns.Error
 }
 
+func (r *Repository) FetchSubmodules(bumps map[string]string) {
+	r.FetchSubmodulesCall.Receives.Bumps = append(r.FetchSubmodulesCall.Receives.Bumps, bumps)
+}
+
 func (r *Repository) BumpSubmodule(patchPath, sha string) error {
 	if len(r.BumpSubmoduleCall.Receives.Submodules) == 0 {
 		r.BumpSubmoduleCall.Receives.Submodules = make(map[string]string)
@@ -116,8 +195,55 @@ func (r *Repository) PatchSubmodule(relativePath, fullPathToPatch string) error
 	return r.PatchSubmoduleCall.Returns.Error
 }
 
+func (r *Repository) ForkSubmodule(path, url string) error {
+	if len(r.ForkSubmoduleCall.Receives.Forks) == 0 {
+		r.ForkSubmoduleCall.Receives.Forks = make(map[string]string)
+	}
+
+	r.ForkSubmoduleCall.Receives.Forks[path] = url
+
+	return r.ForkSubmoduleCall.Returns.Error
+}
+
 func (r *Repository) CheckoutBranch(name string) error {
 	r.CheckoutBranchCall.Receives.Name = name
 
 	return r.CheckoutBranchCall.Returns.Error
 }
+
+func (r *Repository) DeleteBranch(name string) error {
+	r.DeleteBranchCall.Receives.Names = append(r.DeleteBranchCall.Receives.Names, name)
+
+	return r.DeleteBranchCall.Returns.Error
+}
+
+func (r *Repository) CommitSubmodulePatch(path, patch string) error {
+	r.CommitSubmodulePatchCall.Receives.Path = path
+	r.CommitSubmodulePatchCall.Receives.Patch = patch
+
+	return r.CommitSubmodulePatchCall.Returns.Error
+}
+
+func (r *Repository) PatchInProgress(path string) (bool, error) {
+	r.PatchInProgressCall.Receives.Path = path
+
+	return r.PatchInProgressCall.Returns.InProgress, r.PatchInProgressCall.Returns.Error
+}
+
+func (r *Repository) AbortPatch(path string) error {
+	r.AbortPatchCall.WasCalled = true
+	r.AbortPatchCall.Receives.Path = path
+
+	return r.AbortPatchCall.Returns.Error
+}
+
+func (r *Repository) Head(path string) (string, error) {
+	r.HeadCall.Receives.Paths = append(r.HeadCall.Receives.Paths, path)
+	r.HeadCall.CallCount++
+
+	if r.HeadCall.CallCount <= len(r.HeadCall.Returns.SHAs) {
+		return r.HeadCall.Returns.SHAs[r.HeadCall.CallCount-1], r.HeadCall.Returns.Error
+	}
+
+	return "", r.HeadCall.Returns.Error
+}
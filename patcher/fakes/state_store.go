@@ -0,0 +1,33 @@
+package fakes
+
+import "github.com/pivotal-cf/knit/patcher"
+
+type StateStore struct {
+	SaveCall struct {
+		Receives struct {
+			States []patcher.State
+		}
+		Returns struct {
+			Error error
+		}
+	}
+
+	ClearCall struct {
+		WasCalled bool
+		Returns   struct {
+			Error error
+		}
+	}
+}
+
+func (s *StateStore) Save(state patcher.State) error {
+	s.SaveCall.Receives.States = append(s.SaveCall.Receives.States, state)
+
+	return s.SaveCall.Returns.Error
+}
+
+func (s *StateStore) Clear() error {
+	s.ClearCall.WasCalled = true
+
+	return s.ClearCall.Returns.Error
+}
@@ -0,0 +1,49 @@
+package fakes
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf/knit/patcher"
+)
+
+type Verifier struct {
+	DiffTreesCall struct {
+		Receives struct {
+			Expected string
+			Found    string
+		}
+		Returns struct {
+			Drifts []patcher.Drift
+			Error  error
+		}
+	}
+
+	SubmoduleTreeCall struct {
+		Receives struct {
+			Paths   []string
+			Commits []string
+		}
+		Returns struct {
+			Trees map[string]map[string]patcher.TreeEntry
+		}
+	}
+}
+
+func (v *Verifier) DiffTrees(expected, found string) ([]patcher.Drift, error) {
+	v.DiffTreesCall.Receives.Expected = expected
+	v.DiffTreesCall.Receives.Found = found
+
+	return v.DiffTreesCall.Returns.Drifts, v.DiffTreesCall.Returns.Error
+}
+
+func (v *Verifier) SubmoduleTree(path, commit string) (map[string]patcher.TreeEntry, error) {
+	v.SubmoduleTreeCall.Receives.Paths = append(v.SubmoduleTreeCall.Receives.Paths, path)
+	v.SubmoduleTreeCall.Receives.Commits = append(v.SubmoduleTreeCall.Receives.Commits, commit)
+
+	tree, ok := v.SubmoduleTreeCall.Returns.Trees[commit]
+	if !ok {
+		return nil, errors.New("commit not found")
+	}
+
+	return tree, nil
+}
@@ -0,0 +1,289 @@
+package patcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type PatchRef struct {
+	Repository string
+	Ref        string
+	Commit     string
+	Dir        string
+}
+
+type GitRefSource struct {
+	runner     commandRunner
+	repo       string
+	ref        string
+	sha        string
+	patchesDir string
+	namesOnly  bool
+}
+
+func NewGitRefSource(commandRunner commandRunner, repo, ref string) (GitRefSource, error) {
+	output, err := commandRunner.CombinedOutput(Command{
+		Step: "PatchSource",
+		Args: []string{"rev-parse", "--verify", "--quiet", ref + "^{commit}"},
+		Dir:  repo,
+	})
+	if err != nil {
+		return GitRefSource{}, fmt.Errorf("could not find %s in %s", ref, repo)
+	}
+
+	patchesDir, err := ioutil.TempDir("", "knit-patches")
+	if err != nil {
+		return GitRefSource{}, err
+	}
+
+	return GitRefSource{
+		runner:     commandRunner,
+		repo:       repo,
+		ref:        ref,
+		sha:        strings.TrimSpace(string(output)),
+		patchesDir: patchesDir,
+	}, nil
+}
+
+func ReopenGitRefSource(commandRunner commandRunner, ref PatchRef) (GitRefSource, error) {
+	source, err := NewGitRefSource(commandRunner, ref.Repository, ref.Commit)
+	if err != nil {
+		return GitRefSource{}, err
+	}
+
+	source.ref = ref.Ref
+
+	return source, nil
+}
+
+func (s GitRefSource) Close() error {
+	return os.RemoveAll(s.patchesDir)
+}
+
+func (s GitRefSource) PatchRef() PatchRef {
+	return PatchRef{
+		Repository: s.repo,
+		Ref:        s.ref,
+		Commit:     s.sha,
+		Dir:        s.patchesDir,
+	}
+}
+
+func (s GitRefSource) Names() GitRefSource {
+	s.namesOnly = true
+
+	return s
+}
+
+func (s GitRefSource) Restore(state State) (State, error) {
+	if state.PatchRef == nil {
+		return state, nil
+	}
+
+	previousDir := state.PatchRef.Dir
+
+	checkpoints, err := s.restoreCheckpoints(previousDir, []Checkpoint{state.Checkpoint})
+	if err != nil {
+		return State{}, err
+	}
+	state.Checkpoint = checkpoints[0]
+
+	state.Applied, err = s.restoreCheckpoints(previousDir, state.Applied)
+	if err != nil {
+		return State{}, err
+	}
+
+	state.Pending, err = s.restoreCheckpoints(previousDir, state.Pending)
+	if err != nil {
+		return State{}, err
+	}
+
+	ref := s.PatchRef()
+	state.PatchRef = &ref
+
+	return state, nil
+}
+
+func (s GitRefSource) restoreCheckpoints(previousDir string, checkpoints []Checkpoint) ([]Checkpoint, error) {
+	if checkpoints == nil {
+		return nil, nil
+	}
+
+	restored := make([]Checkpoint, len(checkpoints))
+	for i, checkpoint := range checkpoints {
+		changes := make([]Changeset, len(checkpoint.Changes))
+		for j, change := range checkpoint.Changes {
+			patches, err := s.restorePatches(previousDir, change.Patches)
+			if err != nil {
+				return nil, err
+			}
+			change.Patches = patches
+
+			if change.SubmodulePatches != nil {
+				submodulePatches := map[string][]string{}
+				for path, patches := range change.SubmodulePatches {
+					submodulePatches[path], err = s.restorePatches(previousDir, patches)
+					if err != nil {
+						return nil, err
+					}
+				}
+				change.SubmodulePatches = submodulePatches
+			}
+
+			changes[j] = change
+		}
+
+		checkpoint.Changes = changes
+		restored[i] = checkpoint
+	}
+
+	return restored, nil
+}
+
+func (s GitRefSource) restorePatches(previousDir string, patches []string) ([]string, error) {
+	if patches == nil {
+		return nil, nil
+	}
+
+	restored := []string{}
+	for _, patch := range patches {
+		name, err := filepath.Rel(previousDir, patch)
+		if err != nil || strings.HasPrefix(name, "..") {
+			restored = append(restored, patch)
+			continue
+		}
+
+		contents, err := s.show(filepath.ToSlash(name))
+		if err != nil {
+			return nil, err
+		}
+
+		localPath, err := s.write(name, contents)
+		if err != nil {
+			return nil, err
+		}
+
+		restored = append(restored, localPath)
+	}
+
+	return restored, nil
+}
+
+func (s GitRefSource) Releases() ([]string, error) {
+	output, err := s.runner.CombinedOutput(Command{
+		Step: "PatchSource",
+		Args: []string{"ls-tree", "-r", "--name-only", s.sha},
+		Dir:  s.repo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list %s: %s", s.ref, strings.TrimSpace(string(output)))
+	}
+
+	var releases []string
+	for _, name := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if path.Base(name) == startingVersionsFileName {
+			releases = append(releases, path.Dir(name))
+		}
+	}
+	sort.Strings(releases)
+
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("no %s files found in %s at %s", startingVersionsFileName, s.repo, s.ref)
+	}
+
+	return releases, nil
+}
+
+func (s GitRefSource) StartingVersions(release string) (StartingVersions, error) {
+	releaseDirName, err := s.releaseDirName(release)
+	if err != nil {
+		return StartingVersions{}, err
+	}
+
+	contents, err := s.show(path.Join(releaseDirName, startingVersionsFileName))
+	if err != nil {
+		return StartingVersions{}, err
+	}
+
+	return decodeStartingVersions(s.File(release), contents)
+}
+
+func (s GitRefSource) PatchPath(release, patch string) (string, error) {
+	releaseDirName, err := s.releaseDirName(release)
+	if err != nil {
+		return "", err
+	}
+
+	name := path.Join(releaseDirName, filepath.ToSlash(patch))
+
+	contents, err := s.show(name)
+	if err != nil {
+		return "", err
+	}
+
+	if s.namesOnly {
+		return fmt.Sprintf("%s:%s", s.ref, name), nil
+	}
+
+	return s.write(filepath.Join(filepath.FromSlash(releaseDirName), patch), contents)
+}
+
+func (s GitRefSource) write(name string, contents []byte) (string, error) {
+	localPath := filepath.Join(s.patchesDir, name)
+
+	err := os.MkdirAll(filepath.Dir(localPath), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	err = ioutil.WriteFile(localPath, contents, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return localPath, nil
+}
+
+func (s GitRefSource) File(release string) string {
+	releaseDirName, err := s.releaseDirName(release)
+	if err != nil {
+		releaseDirName = release
+	}
+
+	return fmt.Sprintf("%s:%s", s.ref, path.Join(releaseDirName, startingVersionsFileName))
+}
+
+func (s GitRefSource) releaseDirName(release string) (string, error) {
+	for _, releaseDirName := range releaseDirNames(release) {
+		releaseDirName = filepath.ToSlash(releaseDirName)
+
+		_, err := s.runner.CombinedOutput(Command{
+			Step: "PatchSource",
+			Args: []string{"cat-file", "-e", fmt.Sprintf("%s:%s", s.sha, releaseDirName)},
+			Dir:  s.repo,
+		})
+		if err == nil {
+			return releaseDirName, nil
+		}
+	}
+
+	return "", fmt.Errorf("please provide either major.minor or major/minor for directory structure in %s", s.ref)
+}
+
+func (s GitRefSource) show(name string) ([]byte, error) {
+	output, err := s.runner.Output(Command{
+		Step: "PatchSource",
+		Args: []string{"show", fmt.Sprintf("%s:%s", s.sha, name)},
+		Dir:  s.repo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s:%s: %s", s.ref, name, err)
+	}
+
+	return output, nil
+}
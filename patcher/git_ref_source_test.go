@@ -0,0 +1,235 @@
+package patcher_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pivotal-cf/knit/patcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitRefSource", func() {
+	var (
+		patchesRepo string
+		source      patcher.GitRefSource
+		ps          patcher.PatchSet
+	)
+
+	git := func(args ...string) {
+		command := exec.Command("git", args...)
+		command.Dir = patchesRepo
+		output, err := command.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("Error: %s", output))
+	}
+
+	writeFile := func(path, contents string) {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(patchesRepo, path)), 0755)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(patchesRepo, path), []byte(contents), 0644)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+		patchesRepo, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		git("init")
+		git("config", "user.name", "test")
+		git("config", "user.email", "test@example.com")
+
+		writeFile(filepath.Join("1", "9", "starting-versions.yml"), `---
+starting_versions:
+- version: 1
+  ref: v191
+  patches:
+  - Top-1.patch
+`)
+		writeFile(filepath.Join("1", "9", "Top-1.patch"), "the committed patch")
+		git("add", "-A")
+		git("commit", "-m", "patches for 1.9.1")
+		git("tag", "pinned")
+
+		writeFile(filepath.Join("1", "9", "Top-1.patch"), "a patch that is not committed")
+		writeFile(filepath.Join("1", "9", "starting-versions.yml"), "%%%")
+	})
+
+	JustBeforeEach(func() {
+		runner, err := patcher.NewCommandRunner("git", true)
+		Expect(err).NotTo(HaveOccurred())
+
+		source, err = patcher.NewGitRefSource(runner, patchesRepo, "pinned")
+		Expect(err).NotTo(HaveOccurred())
+
+		ps = patcher.NewPatchSetFromSource(source)
+	})
+
+	AfterEach(func() {
+		Expect(source.Close()).To(Succeed())
+
+		err := os.RemoveAll(patchesRepo)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reads the starting versions and patches from the ref instead of the work tree", func() {
+		versions, err := ps.VersionsToApplyFor("1.9.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(versions).To(HaveLen(1))
+		Expect(versions[0].Ref).To(Equal("v191"))
+		Expect(versions[0].Patches).To(HaveLen(1))
+
+		contents, err := ioutil.ReadFile(versions[0].Patches[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("the committed patch"))
+	})
+
+	It("writes the patches to a private directory that is removed on close", func() {
+		versions, err := ps.VersionsToApplyFor("1.9.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		patchesDir := filepath.Dir(filepath.Dir(filepath.Dir(versions[0].Patches[0])))
+		Expect(filepath.Base(patchesDir)).To(HavePrefix("knit-patches"))
+
+		info, err := os.Stat(patchesDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+
+		Expect(source.Close()).To(Succeed())
+		Expect(patchesDir).NotTo(BeADirectory())
+	})
+
+	Context("when only the names of the patches are needed", func() {
+		It("names the patches after the ref instead of copying them", func() {
+			versions, err := patcher.NewPatchSetFromSource(source.Names()).VersionsToApplyFor("1.9.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(versions[0].Patches).To(Equal([]string{"pinned:1/9/Top-1.patch"}))
+
+			entries, err := ioutil.ReadDir(source.PatchRef().Dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	It("keeps reading the commit the ref pointed to when the ref moves", func() {
+		writeFile(filepath.Join("1", "9", "Top-1.patch"), "a later patch")
+		git("commit", "-am", "change the patch")
+		git("tag", "-f", "pinned")
+
+		versions, err := ps.VersionsToApplyFor("1.9.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := ioutil.ReadFile(versions[0].Patches[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("the committed patch"))
+	})
+
+	It("validates the releases at the ref", func() {
+		problems, err := ps.Validate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(problems).To(BeEmpty())
+	})
+
+	Describe("Restore", func() {
+		It("reads the patches of an interrupted run again into its own directory", func() {
+			versions, err := ps.VersionsToApplyFor("1.9.1")
+			Expect(err).NotTo(HaveOccurred())
+
+			ref := source.PatchRef()
+			Expect(ref.Repository).To(Equal(patchesRepo))
+			Expect(ref.Ref).To(Equal("pinned"))
+
+			patch := versions[0].Patches[0]
+			state := patcher.State{
+				Checkpoint: patcher.Checkpoint{
+					Changes: []patcher.Changeset{{
+						Patches:          []string{patch},
+						SubmodulePatches: map[string][]string{"src/sub": []string{patch, "/elsewhere/other.patch"}},
+					}},
+					FinalBranch: "1.9.1",
+				},
+				PatchRef: &ref,
+			}
+
+			Expect(source.Close()).To(Succeed())
+			git("tag", "-d", "pinned")
+
+			runner, err := patcher.NewCommandRunner("git", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			reopened, err := patcher.ReopenGitRefSource(runner, ref)
+			Expect(err).NotTo(HaveOccurred())
+			defer reopened.Close()
+
+			restored, err := reopened.Restore(state)
+			Expect(err).NotTo(HaveOccurred())
+
+			newRef := reopened.PatchRef()
+			Expect(restored.PatchRef).To(Equal(&newRef))
+			Expect(newRef.Ref).To(Equal("pinned"))
+			Expect(newRef.Dir).NotTo(Equal(ref.Dir))
+
+			restoredPatch := restored.Checkpoint.Changes[0].Patches[0]
+			Expect(restoredPatch).To(HavePrefix(newRef.Dir))
+			Expect(restored.Checkpoint.Changes[0].SubmodulePatches).To(Equal(map[string][]string{
+				"src/sub": []string{restoredPatch, "/elsewhere/other.patch"},
+			}))
+
+			contents, err := ioutil.ReadFile(restoredPatch)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("the committed patch"))
+		})
+
+		It("leaves a state without a patch ref alone", func() {
+			state := patcher.State{Checkpoint: patcher.Checkpoint{FinalBranch: "1.9.1"}}
+
+			restored, err := source.Restore(state)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored).To(Equal(state))
+		})
+	})
+
+	Context("when the release does not exist at the ref", func() {
+		It("returns an error", func() {
+			_, err := ps.VersionsToApplyFor("2.0.0")
+			Expect(err).To(MatchError("please provide either major.minor or major/minor for directory structure in pinned"))
+		})
+	})
+
+	Context("when a patch does not exist at the ref", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join("1", "9", "starting-versions.yml"), `---
+starting_versions:
+- version: 1
+  ref: v191
+  patches:
+  - Missing.patch
+`)
+			git("add", "-A")
+			git("commit", "-m", "reference a missing patch")
+			git("tag", "-f", "pinned")
+		})
+
+		It("returns an error", func() {
+			_, err := ps.VersionsToApplyFor("1.9.1")
+			Expect(err).To(MatchError(ContainSubstring("could not read pinned:1/9/Missing.patch")))
+		})
+	})
+
+	Context("when the ref does not exist", func() {
+		It("returns an error", func() {
+			runner, err := patcher.NewCommandRunner("git", true)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = patcher.NewGitRefSource(runner, patchesRepo, "missing")
+			Expect(err).To(MatchError(fmt.Sprintf("could not find missing in %s", patchesRepo)))
+		})
+	})
+})
@@ -0,0 +1,141 @@
+package patcher
+
+import (
+	"fmt"
+	"sort"
+)
+
+func (v StartingVersion) withHotfixes(names []string) (StartingVersion, error) {
+	ordered, err := orderHotfixes(v.Hotfixes, names)
+	if err != nil {
+		return StartingVersion{}, err
+	}
+
+	v.Patches = append([]string{}, v.Patches...)
+
+	submodules := map[string]Submodule{}
+	for path, submodule := range v.Submodules {
+		submodules[path] = submodule
+	}
+
+	refSetBy := map[string]string{}
+	addedBy := map[string]string{}
+	removedBy := map[string]string{}
+	for _, name := range ordered {
+		hotfix := v.Hotfixes[name]
+		v.Patches = append(v.Patches, hotfix.Patches...)
+
+		var paths []string
+		for path := range hotfix.Submodules {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			submodule := hotfix.Submodules[path]
+			merged := submodules[path]
+
+			if submodule.Remove {
+				if other, ok := refSetBy[path]; ok {
+					return StartingVersion{}, fmt.Errorf("hotfixes %q and %q both bump and remove submodule %q", other, name, path)
+				}
+
+				if merged.Add.URL != "" {
+					delete(submodules, path)
+				} else {
+					submodules[path] = Submodule{Remove: true}
+				}
+
+				delete(addedBy, path)
+				removedBy[path] = name
+				continue
+			}
+
+			if submodule.Ref != "" {
+				if other, ok := removedBy[path]; ok && submodule.Add.URL == "" {
+					return StartingVersion{}, fmt.Errorf("hotfixes %q and %q both bump and remove submodule %q", other, name, path)
+				}
+
+				if other, ok := refSetBy[path]; ok && merged.Ref != submodule.Ref {
+					return StartingVersion{}, fmt.Errorf("hotfixes %q and %q bump submodule %q to different refs (%s and %s)", other, name, path, merged.Ref, submodule.Ref)
+				}
+
+				merged.Ref = submodule.Ref
+				refSetBy[path] = name
+			}
+
+			if submodule.Add.URL != "" {
+				if other, ok := addedBy[path]; ok && merged.Add != submodule.Add {
+					return StartingVersion{}, fmt.Errorf("hotfixes %q and %q add submodule %q differently", other, name, path)
+				}
+
+				merged.Add = submodule.Add
+				merged.Remove = false
+				addedBy[path] = name
+				delete(removedBy, path)
+			}
+
+			if submodule.Fork != "" {
+				merged.Fork = submodule.Fork
+			}
+
+			merged.Patches = append(append([]string{}, merged.Patches...), submodule.Patches...)
+			submodules[path] = merged
+		}
+	}
+	v.Submodules = submodules
+
+	return v, nil
+}
+
+func orderHotfixes(hotfixes map[string]Hotfix, names []string) ([]string, error) {
+	var (
+		ordered  []string
+		visited  = map[string]bool{}
+		visiting = map[string]bool{}
+	)
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+
+		if visiting[name] {
+			return fmt.Errorf("hotfix %q has a circular depends_on", name)
+		}
+
+		hotfix, ok := hotfixes[name]
+		if !ok {
+			return fmt.Errorf("Hotfix not found: %q", name)
+		}
+
+		visiting[name] = true
+
+		dependencies := append([]string{}, hotfix.DependsOn...)
+		sort.Strings(dependencies)
+
+		for _, dependency := range dependencies {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+
+		visiting[name] = false
+		visited[name] = true
+		ordered = append(ordered, name)
+
+		return nil
+	}
+
+	requested := append([]string{}, names...)
+	sort.Strings(requested)
+
+	for _, name := range requested {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,80 @@
+package patcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+type manifest struct {
+	Releases map[string]StartingVersions `yaml:"releases"`
+}
+
+type ManifestSource struct {
+	path string
+}
+
+func NewManifestSource(path string) ManifestSource {
+	return ManifestSource{
+		path: path,
+	}
+}
+
+func (s ManifestSource) Releases() ([]string, error) {
+	m, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []string
+	for release := range m.Releases {
+		releases = append(releases, release)
+	}
+	sort.Strings(releases)
+
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("no releases found in %s", s.path)
+	}
+
+	return releases, nil
+}
+
+func (s ManifestSource) StartingVersions(release string) (StartingVersions, error) {
+	m, err := s.load()
+	if err != nil {
+		return StartingVersions{}, err
+	}
+
+	startingVersions, ok := m.Releases[release]
+	if !ok {
+		return StartingVersions{}, fmt.Errorf("release %q not found in %s", release, s.path)
+	}
+
+	return startingVersions, nil
+}
+
+func (s ManifestSource) PatchPath(release, patch string) (string, error) {
+	return filepath.Join(filepath.Dir(s.path), patch), nil
+}
+
+func (s ManifestSource) File(release string) string {
+	return s.path
+}
+
+func (s ManifestSource) load() (manifest, error) {
+	contents, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return manifest{}, err
+	}
+
+	var m manifest
+	err = yaml.UnmarshalStrict(contents, &m)
+	if err != nil {
+		return manifest{}, newYAMLErrors(s.path, err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,101 @@
+package patcher_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/knit/patcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ManifestSource", func() {
+	var (
+		tmpDir       string
+		manifestPath string
+		ps           patcher.PatchSet
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		manifestPath = filepath.Join(tmpDir, "manifest.yml")
+		err = ioutil.WriteFile(manifestPath, []byte(`---
+releases:
+  "1.9":
+    starting_versions:
+    - version: 1
+      ref: v191
+      patches:
+      - 1.9/Top-1.patch
+  "1.10":
+    starting_versions:
+    - version: 0
+      ref: v1100
+      patches:
+      - 1.10/Missing.patch
+`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.MkdirAll(filepath.Join(tmpDir, "1.9"), 0755)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(tmpDir, "1.9", "Top-1.patch"), []byte{}, 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		ps = patcher.NewPatchSetFromSource(patcher.NewManifestSource(manifestPath))
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reads every release from the manifest with patches relative to it", func() {
+		versions, err := ps.VersionsToApplyFor("1.9.1")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(versions).To(HaveLen(1))
+		Expect(versions[0].Ref).To(Equal("v191"))
+		Expect(versions[0].Patches).To(Equal([]string{filepath.Join(tmpDir, "1.9", "Top-1.patch")}))
+
+		versions, err = ps.VersionsToApplyFor("1.10.0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(versions[0].Ref).To(Equal("v1100"))
+	})
+
+	It("validates every release", func() {
+		problems, err := ps.Validate()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(problems).To(Equal([]patcher.Problem{
+			{File: manifestPath, Message: `version 0: missing patch file "1.10/Missing.patch"`},
+		}))
+	})
+
+	Context("when the release is not in the manifest", func() {
+		It("returns an error", func() {
+			_, err := ps.VersionsToApplyFor("2.0.0")
+			Expect(err).To(MatchError(`release "2.0" not found in ` + manifestPath))
+		})
+	})
+
+	Context("when the manifest contains unknown keys", func() {
+		BeforeEach(func() {
+			err := ioutil.WriteFile(manifestPath, []byte(`---
+release:
+  "1.9": {}
+`), 0644)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns an error with the line number", func() {
+			_, err := ps.VersionsToApplyFor("1.9.1")
+			Expect(err).To(MatchError(manifestPath + `:2: unknown key "release" in manifest`))
+		})
+	})
+})
@@ -1,25 +1,30 @@
 package patcher
 
 import (
-	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
+const startingVersionsFileName = "starting-versions.yml"
+
 type StartingVersions struct {
-	Versions []struct {
-		Version    int
-		Ref        string
-		Submodules map[string]Submodule
-		Patches    []string
-		Hotfixes   map[string]Hotfix
-	} `yaml:"starting_versions"`
+	Versions       []StartingVersion `yaml:"starting_versions"`
+	Ranges         []VersionRange    `yaml:"ranges"`
+	CommitMessages CommitMessages    `yaml:"commit_messages"`
+}
+
+type StartingVersion struct {
+	Version    int
+	PreRelease string `yaml:"pre_release"`
+	Extends    string
+	Ref        string
+	Submodules map[string]Submodule
+	Patches    []string
+	Hotfixes   map[string]Hotfix
 }
 
 type Submodule struct {
@@ -27,198 +32,302 @@ type Submodule struct {
 	Patches []string
 	Add     SubmoduleAddition
 	Remove  bool
+	Fork    string
 }
 
 type SubmoduleAddition struct {
-	URL    string
-	Ref    string
-	Branch string
+	URL    string `json:"url"`
+	Ref    string `json:"ref"`
+	Branch string `json:"branch"`
 }
 
 type Hotfix struct {
+	DependsOn  []string `yaml:"depends_on"`
 	Patches    []string
 	Submodules map[string]Submodule
 }
 
 type PatchSet struct {
-	path string
+	source PatchSource
 }
 
 func NewPatchSet(path string) PatchSet {
-	return PatchSet{path}
+	return NewPatchSetFromSource(NewDirectorySource(path))
+}
+
+func NewPatchSetFromSource(source PatchSource) PatchSet {
+	return PatchSet{source}
 }
 
 type Version struct {
 	Major              int
 	Minor              int
 	Patch              int
+	PreRelease         string
 	Ref                string
 	Patches            []string
 	SubmoduleBumps     map[string]string
 	SubmodulePatches   map[string][]string
 	SubmoduleAdditions map[string]SubmoduleAddition
 	SubmoduleRemovals  []string
+	SubmoduleForks     map[string]string
+	CommitMessages     CommitMessages
 }
 
 func (ps PatchSet) VersionsToApplyFor(version string) ([]Version, error) {
-	majorVersion, minorVersion, patchVersion, hotfixVersion, err := ps.parseVersion(version)
+	target, err := ParseSemVer(version)
+	if err != nil {
+		return nil, err
+	}
+
+	release := fmt.Sprintf("%d.%d", target.Major, target.Minor)
+
+	startingVersions, err := ps.source.StartingVersions(release)
 	if err != nil {
 		return nil, err
 	}
 
-	releaseDirName, err := ps.releaseDirName(majorVersion, minorVersion)
+	targetVersion := SemVer{Patch: target.Patch, PreRelease: target.PreRelease}
+
+	effective := -1
+	for i, v := range startingVersions.Versions {
+		if v.semVer().Compare(targetVersion) > 0 {
+			continue
+		}
+
+		if effective < 0 || v.semVer().Compare(startingVersions.Versions[effective].semVer()) >= 0 {
+			effective = i
+		}
+	}
+
+	if effective < 0 {
+		if len(target.Hotfixes) > 0 {
+			return nil, fmt.Errorf("Hotfix not found: %q", target.Hotfixes[0])
+		}
+
+		return nil, nil
+	}
+
+	chain, err := versionChain(startingVersions.Versions, effective)
 	if err != nil {
 		return nil, err
 	}
 
-	startingVersions, err := ps.parseStartingVersionsFile(releaseDirName)
+	chain, err = applyRanges(chain, startingVersions.Ranges, targetVersion)
 	if err != nil {
 		return nil, err
 	}
 
-	var versions []Version
-	var effectiveVersion Version
-	for _, v := range startingVersions.Versions {
-		vers := Version{
-			Major:              majorVersion,
-			Minor:              minorVersion,
-			Patch:              v.Version,
-			Ref:                v.Ref,
-			SubmoduleBumps:     map[string]string{},
-			SubmodulePatches:   map[string][]string{},
-			SubmoduleAdditions: map[string]SubmoduleAddition{},
-			SubmoduleRemovals:  []string{},
-		}
-
-		if v.Version == patchVersion && hotfixVersion != "" {
-			if _, ok := v.Hotfixes[hotfixVersion]; ok {
-				v.Patches = append(v.Patches, v.Hotfixes[hotfixVersion].Patches...)
-
-				for path, submodule := range v.Hotfixes[hotfixVersion].Submodules {
-					if v.Submodules == nil {
-						v.Submodules = make(map[string]Submodule)
-					}
-
-					if _, ok := v.Submodules[path]; !ok {
-						v.Submodules[path] = Submodule{}
-					}
-
-					hotfixRef := v.Submodules[path].Ref
-					if submodule.Ref != "" {
-						hotfixRef = submodule.Ref
-					}
-
-					v.Submodules[path] = Submodule{
-						Ref:     hotfixRef,
-						Patches: append(v.Submodules[path].Patches, submodule.Patches...),
-					}
-				}
-			} else {
-				return nil, fmt.Errorf("Hotfix not found: %q", hotfixVersion)
+	var versionsToApply []Version
+	forks := map[string]string{}
+	hotfixed := false
+	for _, v := range chain {
+		if len(target.Hotfixes) > 0 && v.Version == target.Patch && v.PreRelease == target.PreRelease {
+			v, err = v.withHotfixes(target.Hotfixes)
+			if err != nil {
+				return nil, err
 			}
+
+			hotfixed = true
 		}
 
-		for _, patch := range v.Patches {
-			vers.Patches = append(vers.Patches, filepath.Join(ps.path, releaseDirName, patch))
+		vers, err := ps.newVersion(release, target, v)
+		if err != nil {
+			return nil, err
 		}
 
-		for path, submodule := range v.Submodules {
-			if submodule.Ref != "" {
-				vers.SubmoduleBumps[path] = submodule.Ref
-			}
+		vers.inheritForks(forks)
+		vers.CommitMessages = startingVersions.CommitMessages
+		versionsToApply = append(versionsToApply, vers)
+	}
 
-			submodulePatches := []string{}
+	if len(target.Hotfixes) > 0 && !hotfixed {
+		return nil, fmt.Errorf("Hotfix not found: %q", target.Hotfixes[0])
+	}
 
-			for _, patch := range submodule.Patches {
-				submodulePatches = append(submodulePatches, filepath.Join(ps.path, releaseDirName, patch))
-			}
+	return versionsToApply, nil
+}
 
-			if len(submodulePatches) > 0 {
-				vers.SubmodulePatches[path] = submodulePatches
-			}
+func (ps PatchSet) newVersion(release string, target SemVer, v StartingVersion) (Version, error) {
+	vers := Version{
+		Major:              target.Major,
+		Minor:              target.Minor,
+		Patch:              v.Version,
+		PreRelease:         v.PreRelease,
+		Ref:                v.Ref,
+		SubmoduleBumps:     map[string]string{},
+		SubmodulePatches:   map[string][]string{},
+		SubmoduleAdditions: map[string]SubmoduleAddition{},
+		SubmoduleRemovals:  []string{},
+	}
+
+	for _, patch := range v.Patches {
+		patchPath, err := ps.source.PatchPath(release, patch)
+		if err != nil {
+			return Version{}, err
+		}
+
+		vers.Patches = append(vers.Patches, patchPath)
+	}
+
+	var paths []string
+	for path := range v.Submodules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		submodule := v.Submodules[path]
+		if submodule.Ref != "" {
+			vers.SubmoduleBumps[path] = submodule.Ref
+		}
 
-			if submodule.Add.URL != "" {
-				if submodule.Add.Ref == "" {
-					return nil, fmt.Errorf("Missing ref for new submodule: %q", path)
-				}
+		submodulePatches := []string{}
 
-				vers.SubmoduleAdditions[path] = submodule.Add
+		for _, patch := range submodule.Patches {
+			patchPath, err := ps.source.PatchPath(release, patch)
+			if err != nil {
+				return Version{}, err
 			}
 
-			if submodule.Remove {
-				vers.SubmoduleRemovals = append(vers.SubmoduleRemovals, path)
+			submodulePatches = append(submodulePatches, patchPath)
+		}
+
+		if len(submodulePatches) > 0 {
+			vers.SubmodulePatches[path] = submodulePatches
+		}
+
+		if submodule.Add.URL != "" {
+			if submodule.Add.Ref == "" {
+				return Version{}, fmt.Errorf("Missing ref for new submodule: %q", path)
 			}
+
+			vers.SubmoduleAdditions[path] = submodule.Add
 		}
 
-		if v.Version <= patchVersion {
-			effectiveVersion = vers
+		if submodule.Remove {
+			vers.SubmoduleRemovals = append(vers.SubmoduleRemovals, path)
 		}
 
-		versions = append(versions, vers)
-	}
+		if submodule.Fork != "" {
+			if vers.SubmoduleForks == nil {
+				vers.SubmoduleForks = map[string]string{}
+			}
 
-	var versionsToApply []Version
-	for _, v := range versions {
-		if v.Ref == effectiveVersion.Ref && v.Patch <= effectiveVersion.Patch {
-			versionsToApply = append(versionsToApply, v)
+			vers.SubmoduleForks[path] = submodule.Fork
 		}
 	}
 
-	return versionsToApply, nil
+	return vers, nil
 }
 
-func (ps PatchSet) parseVersion(version string) (int, int, int, string, error) {
-	hotfixParts := strings.Split(version, "+")
+func (v *Version) inheritForks(forks map[string]string) {
+	for _, path := range v.SubmoduleRemovals {
+		delete(forks, path)
+	}
 
-	versionParts := strings.Split(hotfixParts[0], ".")
-	majorVersion, err := strconv.Atoi(versionParts[0])
-	if err != nil {
-		return -1, -1, -1, "", err
+	for path, fork := range v.SubmoduleForks {
+		forks[path] = fork
 	}
 
-	minorVersion, err := strconv.Atoi(versionParts[1])
-	if err != nil {
-		return -1, -1, -1, "", err
+	var paths []string
+	for path := range v.SubmodulePatches {
+		paths = append(paths, path)
 	}
 
-	patchVersion, err := strconv.Atoi(versionParts[2])
-	if err != nil {
-		return -1, -1, -1, "", err
+	for path := range v.SubmoduleBumps {
+		paths = append(paths, path)
 	}
 
-	var hotfixVersion string
-	if len(hotfixParts) > 1 {
-		hotfixVersion = hotfixParts[1]
+	for path := range v.SubmoduleAdditions {
+		paths = append(paths, path)
 	}
 
-	return majorVersion, minorVersion, patchVersion, hotfixVersion, nil
+	for _, path := range paths {
+		fork, ok := forks[path]
+		if !ok || v.SubmoduleForks[path] != "" {
+			continue
+		}
+
+		if v.SubmoduleForks == nil {
+			v.SubmoduleForks = map[string]string{}
+		}
+
+		v.SubmoduleForks[path] = fork
+	}
 }
 
-func (ps PatchSet) releaseDirName(majorVersion, minorVersion int) (string, error) {
-	path := filepath.Join(ps.path, fmt.Sprintf("%d.%d", majorVersion, minorVersion))
-	_, err := os.Stat(path)
-	if err == nil {
-		return fmt.Sprintf("%d.%d", majorVersion, minorVersion), nil
+func (v StartingVersion) semVer() SemVer {
+	return SemVer{
+		Patch:      v.Version,
+		PreRelease: v.PreRelease,
 	}
+}
 
-	path = filepath.Join(ps.path, fmt.Sprintf("%d", majorVersion), fmt.Sprintf("%d", minorVersion))
-	_, err = os.Stat(path)
-	if err == nil {
-		return filepath.Join(fmt.Sprintf("%d", majorVersion), fmt.Sprintf("%d", minorVersion)), nil
+func (v StartingVersion) id() string {
+	if v.PreRelease == "" {
+		return fmt.Sprintf("%d", v.Version)
 	}
-	return "", errors.New("please provide either major.minor or major/minor for directory structure")
+
+	return fmt.Sprintf("%d-%s", v.Version, v.PreRelease)
 }
 
-func (ps PatchSet) parseStartingVersionsFile(releaseDirName string) (StartingVersions, error) {
-	startingVersionsYAML, err := ioutil.ReadFile(filepath.Join(ps.path, releaseDirName, "starting-versions.yml"))
+func (ps PatchSet) AllVersions(release string) ([]string, error) {
+	releaseParts := strings.Split(release, ".")
+	if len(releaseParts) != 2 {
+		return nil, fmt.Errorf("invalid release %q, expected major.minor", release)
+	}
+
+	majorVersion, err := strconv.Atoi(releaseParts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	minorVersion, err := strconv.Atoi(releaseParts[1])
 	if err != nil {
-		return StartingVersions{}, errors.New("please provide a starting-versions.yml file")
+		return nil, err
 	}
 
+	startingVersions, err := ps.source.StartingVersions(fmt.Sprintf("%d.%d", majorVersion, minorVersion))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := startingVersions.Versions
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no versions found for %d.%d", majorVersion, minorVersion)
+	}
+	sortStartingVersions(entries)
+
+	var versions []string
+	for _, v := range entries {
+		version := SemVer{
+			Major:      majorVersion,
+			Minor:      minorVersion,
+			Patch:      v.Version,
+			PreRelease: v.PreRelease,
+		}.String()
+		versions = append(versions, version)
+
+		var hotfixNames []string
+		for name := range v.Hotfixes {
+			hotfixNames = append(hotfixNames, name)
+		}
+		sort.Strings(hotfixNames)
+
+		for _, name := range hotfixNames {
+			versions = append(versions, fmt.Sprintf("%s+%s", version, name))
+		}
+	}
+
+	return versions, nil
+}
+
+func decodeStartingVersions(file string, contents []byte) (StartingVersions, error) {
 	var startingVersions StartingVersions
-	err = yaml.Unmarshal(startingVersionsYAML, &startingVersions)
+	err := yaml.UnmarshalStrict(contents, &startingVersions)
 	if err != nil {
-		return StartingVersions{}, err
+		return StartingVersions{}, newYAMLErrors(file, err)
 	}
 
 	return startingVersions, nil
@@ -1,6 +1,7 @@
 package patcher_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -293,6 +294,108 @@ var _ = Describe("PatchSet", func() {
 						}).NotTo(Panic())
 					})
 				})
+
+				Context("when the hotfix adds or removes submodules", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+starting_versions:
+- version: 2
+  ref: 'v124'
+  submodules:
+    "src/base-added":
+      add:
+        url: base-url
+        ref: base-sha
+    "src/base-removed":
+      remove: true
+  hotfixes:
+    "add-sub":
+      submodules:
+        "src/new-sub":
+          add:
+            url: new-url
+            ref: new-sha
+            branch: new-branch
+        "src/base-added":
+          patches:
+          - Sub-1.patch
+    "remove-sub":
+      submodules:
+        "src/old-sub":
+          remove: true
+        "src/base-added":
+          remove: true
+    "re-add":
+      submodules:
+        "src/base-removed":
+          add:
+            url: readd-url
+            ref: readd-sha
+    "add-other":
+      submodules:
+        "src/new-sub":
+          add:
+            url: other-url
+            ref: other-sha
+    "bump-old":
+      submodules:
+        "src/old-sub":
+          ref: bumped-sha
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("adds the hotfix submodules with their branch", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2+add-sub")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions[0].SubmoduleAdditions).To(Equal(map[string]patcher.SubmoduleAddition{
+							"src/base-added": {URL: "base-url", Ref: "base-sha"},
+							"src/new-sub":    {URL: "new-url", Ref: "new-sha", Branch: "new-branch"},
+						}))
+						Expect(versions[0].SubmodulePatches).To(Equal(map[string][]string{
+							"src/base-added": {filepath.Join(patchesRepo, "1.9", "Sub-1.patch")},
+						}))
+						Expect(versions[0].SubmoduleRemovals).To(Equal([]string{"src/base-removed"}))
+					})
+
+					It("removes the hotfix submodules and drops the additions they remove", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2+remove-sub")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions[0].SubmoduleAdditions).To(BeEmpty())
+						Expect(versions[0].SubmoduleRemovals).To(Equal([]string{"src/base-removed", "src/old-sub"}))
+					})
+
+					It("drops the patches of an addition that a later hotfix removes", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2+add-sub+remove-sub")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions[0].SubmoduleAdditions).To(Equal(map[string]patcher.SubmoduleAddition{
+							"src/new-sub": {URL: "new-url", Ref: "new-sha", Branch: "new-branch"},
+						}))
+						Expect(versions[0].SubmodulePatches).To(BeEmpty())
+						Expect(versions[0].SubmoduleRemovals).To(Equal([]string{"src/base-removed", "src/old-sub"}))
+					})
+
+					It("reports hotfixes that bump and remove the same submodule", func() {
+						_, err := ps.VersionsToApplyFor("1.9.2+bump-old+remove-sub")
+						Expect(err).To(MatchError(`hotfixes "bump-old" and "remove-sub" both bump and remove submodule "src/old-sub"`))
+					})
+
+					It("lets a hotfix add back a submodule the version removes", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2+re-add")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions[0].SubmoduleAdditions).To(HaveKeyWithValue("src/base-removed", patcher.SubmoduleAddition{URL: "readd-url", Ref: "readd-sha"}))
+						Expect(versions[0].SubmoduleRemovals).To(BeEmpty())
+					})
+
+					It("reports hotfixes that add the same submodule differently", func() {
+						_, err := ps.VersionsToApplyFor("1.9.2+add-sub+add-other")
+						Expect(err).To(MatchError(`hotfixes "add-other" and "add-sub" add submodule "src/new-sub" differently`))
+					})
+				})
 			})
 
 			Context("when an error occurs", func() {
@@ -325,7 +428,14 @@ var _ = Describe("PatchSet", func() {
 					Context("when the version can't be parsed", func() {
 						It("returns an error", func() {
 							_, err := ps.VersionsToApplyFor("%$#")
-							Expect(err).To(MatchError(ContainSubstring("invalid syntax")))
+							Expect(err).To(MatchError(`invalid version "%$#": expected major.minor.patch`))
+						})
+					})
+
+					Context("when the version has no patch number", func() {
+						It("returns an error instead of panicking", func() {
+							_, err := ps.VersionsToApplyFor("1.9")
+							Expect(err).To(MatchError(`invalid version "1.9": expected major.minor.patch`))
 						})
 					})
 				})
@@ -338,7 +448,459 @@ var _ = Describe("PatchSet", func() {
 
 					It("returns an error", func() {
 						_, err := ps.VersionsToApplyFor("1.9.2")
-						Expect(err).To(MatchError("yaml: could not find expected directive name"))
+						Expect(err).To(MatchError(startingVersionsYAML + ": could not find expected directive name"))
+					})
+				})
+
+				Context("when the starting versions yaml has a syntax error", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+starting_versions:
+- version: 2
+  ref: 'v124'
+  patches: - Top-1.patch
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("returns an error with the line number", func() {
+						_, err := ps.VersionsToApplyFor("1.9.2")
+						Expect(err).To(MatchError(ContainSubstring(startingVersionsYAML + ":5: ")))
+					})
+				})
+
+				Context("when the starting versions yaml contains unknown keys", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+starting_versions:
+- version: 2
+  ref: 'v124'
+  patchs:
+  - Top-1.patch
+  submodules:
+    "src/fake-sub-1":
+      reff: fake-sha-1
+  hotfixes:
+    "urgent":
+      submodule: {}
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("returns an error with the file, line and key", func() {
+						_, err := ps.VersionsToApplyFor("1.9.2")
+						Expect(err).To(MatchError(startingVersionsYAML + `:5: unknown key "patchs" in version
+` + startingVersionsYAML + `:9: unknown key "reff" in submodule
+` + startingVersionsYAML + `:12: unknown key "submodule" in hotfix`))
+					})
+				})
+
+				Context("when the starting versions yaml has pre-releases", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+starting_versions:
+- version: 1
+  ref: 'v124'
+  patches:
+  - Top-1.patch
+- version: 2
+  ref: 'v124'
+- version: 2
+  pre_release: rc.2
+  ref: 'v124'
+  patches:
+  - Top-2.patch
+- version: 2
+  pre_release: rc.1
+  ref: 'v124'
+  hotfixes:
+    "urgent":
+      patches:
+      - Top-88.patch
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					versionNames := func(versions []patcher.Version) []string {
+						var names []string
+						for _, v := range versions {
+							names = append(names, fmt.Sprintf("%d-%s", v.Patch, v.PreRelease))
+						}
+						return names
+					}
+
+					It("applies the pre-releases that precede the requested version", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2-rc.2")
+						Expect(err).NotTo(HaveOccurred())
+						Expect(versionNames(versions)).To(Equal([]string{"1-", "2-rc.1", "2-rc.2"}))
+
+						versions, err = ps.VersionsToApplyFor("1.9.2-rc.1")
+						Expect(err).NotTo(HaveOccurred())
+						Expect(versionNames(versions)).To(Equal([]string{"1-", "2-rc.1"}))
+
+						versions, err = ps.VersionsToApplyFor("1.9.2")
+						Expect(err).NotTo(HaveOccurred())
+						Expect(versionNames(versions)).To(Equal([]string{"1-", "2-rc.1", "2-rc.2", "2-"}))
+					})
+
+					It("applies hotfixes of a pre-release", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2-rc.1+urgent")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions[len(versions)-1].Patches).To(Equal([]string{
+							filepath.Join(patchesRepo, "1.9", "Top-88.patch"),
+						}))
+					})
+
+					It("lists the pre-releases in precedence order", func() {
+						versions, err := ps.AllVersions("1.9")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions).To(Equal([]string{
+							"1.9.1",
+							"1.9.2-rc.1",
+							"1.9.2-rc.1+urgent",
+							"1.9.2-rc.2",
+							"1.9.2",
+						}))
+					})
+				})
+
+				Context("when the starting versions yaml extends versions and declares ranges", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+ranges:
+- versions: ">=2, <4"
+  patches:
+  - Range.patch
+  submodules:
+    "src/ranged":
+      ref: ranged-sha
+starting_versions:
+- version: 1
+  ref: 'v1'
+  patches:
+  - One.patch
+- version: 2
+  ref: 'v1'
+  patches:
+  - Two.patch
+- version: 3
+  ref: 'v2'
+  extends: "2"
+  patches:
+  - Three.patch
+- version: 4
+  extends: "3"
+  patches:
+  - Four.patch
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					patchesOf := func(versions []patcher.Version) [][]string {
+						var patches [][]string
+						for _, v := range versions {
+							var names []string
+							for _, patch := range v.Patches {
+								names = append(names, filepath.Base(patch))
+							}
+							patches = append(patches, names)
+						}
+						return patches
+					}
+
+					It("builds on the extended versions and applies each range once", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.3")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(patchesOf(versions)).To(Equal([][]string{
+							{"One.patch"},
+							{"Two.patch", "Range.patch"},
+							{"Three.patch"},
+						}))
+
+						var refs []string
+						for _, v := range versions {
+							refs = append(refs, v.Ref)
+						}
+						Expect(refs).To(Equal([]string{"v1", "v1", "v2"}))
+
+						Expect(versions[1].SubmoduleBumps).To(Equal(map[string]string{"src/ranged": "ranged-sha"}))
+						Expect(versions[2].SubmoduleBumps).To(BeEmpty())
+					})
+
+					It("skips ranges the requested version does not fall in", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.1")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(patchesOf(versions)).To(Equal([][]string{{"One.patch"}}))
+
+						versions, err = ps.VersionsToApplyFor("1.9.4")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(patchesOf(versions)).To(Equal([][]string{
+							{"One.patch"},
+							{"Two.patch"},
+							{"Three.patch"},
+							{"Four.patch"},
+						}))
+					})
+
+					Context("when the requested version falls in a range between two starting versions", func() {
+						BeforeEach(func() {
+							err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+ranges:
+- versions: ">=3, <7"
+  patches:
+  - Range.patch
+starting_versions:
+- version: 1
+  ref: 'v1'
+  patches:
+  - One.patch
+- version: 5
+  ref: 'v1'
+  patches:
+  - Five.patch
+- version: 8
+  ref: 'v1'
+  patches:
+  - Eight.patch
+`), 0644)
+							Expect(err).NotTo(HaveOccurred())
+						})
+
+						It("applies the range with the version it builds on", func() {
+							versions, err := ps.VersionsToApplyFor("1.9.4")
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(patchesOf(versions)).To(Equal([][]string{{"One.patch", "Range.patch"}}))
+						})
+
+						It("applies the range with the earliest version in it", func() {
+							versions, err := ps.VersionsToApplyFor("1.9.6")
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(patchesOf(versions)).To(Equal([][]string{{"One.patch"}, {"Five.patch", "Range.patch"}}))
+						})
+
+						It("does not apply the range past its upper bound", func() {
+							versions, err := ps.VersionsToApplyFor("1.9.8")
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(patchesOf(versions)).To(Equal([][]string{{"One.patch"}, {"Five.patch"}, {"Eight.patch"}}))
+						})
+					})
+
+					Context("when a version extends one that does not exist", func() {
+						BeforeEach(func() {
+							err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+starting_versions:
+- version: 3
+  extends: "2"
+`), 0644)
+							Expect(err).NotTo(HaveOccurred())
+						})
+
+						It("returns an error", func() {
+							_, err := ps.VersionsToApplyFor("1.9.3")
+							Expect(err).To(MatchError(`version 3: extends "2", which does not exist`))
+						})
+					})
+
+					Context("when a range cannot be parsed", func() {
+						BeforeEach(func() {
+							err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+ranges:
+- versions: ">=three"
+starting_versions:
+- version: 3
+  ref: v3
+`), 0644)
+							Expect(err).NotTo(HaveOccurred())
+						})
+
+						It("returns an error", func() {
+							_, err := ps.VersionsToApplyFor("1.9.3")
+							Expect(err).To(MatchError(`invalid range ">=three": invalid version "three": expected a patch version such as 3 or 3-rc.1`))
+						})
+					})
+				})
+
+				Context("when several hotfixes are requested", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+starting_versions:
+- version: 2
+  ref: 'v124'
+  patches:
+  - Base.patch
+  submodules:
+    "src/sub":
+      ref: base-sha
+  hotfixes:
+    "sec-123":
+      depends_on:
+      - common
+      patches:
+      - Sec.patch
+      submodules:
+        "src/sub":
+          ref: sec-sha
+    "perf-9":
+      patches:
+      - Perf.patch
+      submodules:
+        "src/other":
+          patches:
+          - Perf-Sub.patch
+    "common":
+      patches:
+      - Common.patch
+    "conflict":
+      submodules:
+        "src/sub":
+          ref: other-sha
+    "loop-a":
+      depends_on:
+      - loop-b
+    "loop-b":
+      depends_on:
+      - loop-a
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("merges them and their dependencies in a deterministic order", func() {
+						for _, version := range []string{"1.9.2+sec-123+perf-9", "1.9.2+perf-9+sec-123"} {
+							versions, err := ps.VersionsToApplyFor(version)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(versions).To(HaveLen(1))
+							Expect(versions[0].Patches).To(Equal([]string{
+								filepath.Join(patchesRepo, "1.9", "Base.patch"),
+								filepath.Join(patchesRepo, "1.9", "Perf.patch"),
+								filepath.Join(patchesRepo, "1.9", "Common.patch"),
+								filepath.Join(patchesRepo, "1.9", "Sec.patch"),
+							}))
+							Expect(versions[0].SubmoduleBumps).To(Equal(map[string]string{"src/sub": "sec-sha"}))
+							Expect(versions[0].SubmodulePatches).To(Equal(map[string][]string{
+								"src/other": {filepath.Join(patchesRepo, "1.9", "Perf-Sub.patch")},
+							}))
+						}
+					})
+
+					It("applies the dependencies of a single hotfix", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2+sec-123")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions[0].Patches).To(Equal([]string{
+							filepath.Join(patchesRepo, "1.9", "Base.patch"),
+							filepath.Join(patchesRepo, "1.9", "Common.patch"),
+							filepath.Join(patchesRepo, "1.9", "Sec.patch"),
+						}))
+					})
+
+					It("reports hotfixes that bump a submodule to different refs", func() {
+						_, err := ps.VersionsToApplyFor("1.9.2+sec-123+conflict")
+						Expect(err).To(MatchError(`hotfixes "conflict" and "sec-123" bump submodule "src/sub" to different refs (other-sha and sec-sha)`))
+					})
+
+					It("reports circular dependencies", func() {
+						_, err := ps.VersionsToApplyFor("1.9.2+loop-a")
+						Expect(err).To(MatchError(`hotfix "loop-a" has a circular depends_on`))
+					})
+
+					It("reports missing hotfixes", func() {
+						_, err := ps.VersionsToApplyFor("1.9.2+perf-9+nope")
+						Expect(err).To(MatchError(`Hotfix not found: "nope"`))
+					})
+				})
+
+				Context("when the starting versions yaml has commit messages", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+commit_messages:
+  bump: "Bump {{.Path}} to {{.NewSHA}}"
+  submodule_patch: "Patch {{.Path}} with {{.PatchFile}}"
+starting_versions:
+- version: 2
+  ref: 'v124'
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("returns them with every version", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions).To(HaveLen(1))
+						Expect(versions[0].CommitMessages).To(Equal(patcher.CommitMessages{
+							Bump:           "Bump {{.Path}} to {{.NewSHA}}",
+							SubmodulePatch: "Patch {{.Path}} with {{.PatchFile}}",
+						}))
+					})
+				})
+
+				Context("when the starting versions yaml forks submodules", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(startingVersionsYAML, []byte(`---
+starting_versions:
+- version: 1
+  ref: 'v124'
+  submodules:
+    "src/forked":
+      fork: https://example.com/fork.git
+      patches:
+      - Fork-1.patch
+- version: 2
+  ref: 'v124'
+  submodules:
+    "src/forked":
+      patches:
+      - Fork-2.patch
+    "src/not-forked":
+      patches:
+      - Other.patch
+- version: 3
+  ref: 'v124'
+  submodules:
+    "src/forked":
+      ref: bumped-sha
+- version: 4
+  ref: 'v124'
+  submodules:
+    "src/forked":
+      remove: true
+- version: 5
+  ref: 'v124'
+  submodules:
+    "src/forked":
+      add:
+        url: https://example.com/upstream.git
+        ref: added-sha
+`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("forks the submodule and keeps forking it for later patches", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.2")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions).To(HaveLen(2))
+						Expect(versions[0].SubmoduleForks).To(Equal(map[string]string{"src/forked": "https://example.com/fork.git"}))
+						Expect(versions[1].SubmoduleForks).To(Equal(map[string]string{"src/forked": "https://example.com/fork.git"}))
+					})
+
+					It("keeps forking the submodule for later bumps, until it is removed", func() {
+						versions, err := ps.VersionsToApplyFor("1.9.5")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(versions).To(HaveLen(5))
+						Expect(versions[2].SubmoduleForks).To(Equal(map[string]string{"src/forked": "https://example.com/fork.git"}))
+						Expect(versions[3].SubmoduleForks).To(BeEmpty())
+						Expect(versions[4].SubmoduleForks).To(BeEmpty())
 					})
 				})
 
@@ -349,6 +911,20 @@ var _ = Describe("PatchSet", func() {
 					})
 				})
 
+				Context("when the hotfix is requested for a version without its own starting version", func() {
+					It("returns an error", func() {
+						_, err := ps.VersionsToApplyFor("1.9.9+something.else")
+						Expect(err).To(MatchError(`Hotfix not found: "something.else"`))
+					})
+				})
+
+				Context("when the hotfix is requested for a version before every starting version", func() {
+					It("returns an error", func() {
+						_, err := ps.VersionsToApplyFor("1.9.0-rc.1+urgent")
+						Expect(err).To(MatchError(`Hotfix not found: "urgent"`))
+					})
+				})
+
 				Context("when a new submodule is added without a ref", func() {
 					BeforeEach(func() {
 						err := ioutil.WriteFile(startingVersionsYAML, []byte(`
@@ -371,5 +947,44 @@ starting_versions:
 				})
 			})
 		})
+
+		Describe("AllVersions", func() {
+			It("returns every version and hotfix of the release", func() {
+				versions, err := ps.AllVersions("1.9")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(versions).To(Equal([]string{
+					"1.9.0",
+					"1.9.2",
+					"1.9.2+something.else",
+					"1.9.3",
+					"1.9.3+urgent",
+				}))
+			})
+
+			Context("when the release is not major.minor", func() {
+				It("returns an error", func() {
+					_, err := ps.AllVersions("1.9.2")
+					Expect(err).To(MatchError(`invalid release "1.9.2", expected major.minor`))
+				})
+			})
+
+			Context("when the release has no starting versions", func() {
+				It("returns an error", func() {
+					err := ioutil.WriteFile(startingVersionsYAML, []byte("starting_versions: []\n"), 0644)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = ps.AllVersions("1.9")
+					Expect(err).To(MatchError("no versions found for 1.9"))
+				})
+			})
+
+			Context("when the release has no directory", func() {
+				It("returns an error", func() {
+					_, err := ps.AllVersions("1.8")
+					Expect(err).To(MatchError(ContainSubstring("please provide either major.minor or major/minor for directory structure")))
+				})
+			})
+		})
 	})
 })
@@ -0,0 +1,117 @@
+package patcher
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type PatchSource interface {
+	Releases() ([]string, error)
+	StartingVersions(release string) (StartingVersions, error)
+	PatchPath(release, patch string) (string, error)
+	File(release string) string
+}
+
+type DirectorySource struct {
+	path string
+}
+
+func NewDirectorySource(path string) DirectorySource {
+	return DirectorySource{
+		path: path,
+	}
+}
+
+func (s DirectorySource) Releases() ([]string, error) {
+	var releases []string
+
+	err := filepath.Walk(s.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		if !info.IsDir() && info.Name() == startingVersionsFileName {
+			release, err := filepath.Rel(s.path, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+
+			releases = append(releases, release)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("no %s files found in %s", startingVersionsFileName, s.path)
+	}
+
+	return releases, nil
+}
+
+func (s DirectorySource) StartingVersions(release string) (StartingVersions, error) {
+	releaseDirName, err := s.releaseDirName(release)
+	if err != nil {
+		return StartingVersions{}, err
+	}
+
+	path := filepath.Join(s.path, releaseDirName, startingVersionsFileName)
+
+	startingVersionsYAML, err := ioutil.ReadFile(path)
+	if err != nil {
+		return StartingVersions{}, errors.New("please provide a starting-versions.yml file")
+	}
+
+	return decodeStartingVersions(path, startingVersionsYAML)
+}
+
+func (s DirectorySource) PatchPath(release, patch string) (string, error) {
+	releaseDirName, err := s.releaseDirName(release)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.path, releaseDirName, patch), nil
+}
+
+func (s DirectorySource) File(release string) string {
+	releaseDirName, err := s.releaseDirName(release)
+	if err != nil {
+		releaseDirName = release
+	}
+
+	return filepath.Join(releaseDirName, startingVersionsFileName)
+}
+
+func (s DirectorySource) releaseDirName(release string) (string, error) {
+	for _, releaseDirName := range releaseDirNames(release) {
+		_, err := os.Stat(filepath.Join(s.path, releaseDirName))
+		if err == nil {
+			return releaseDirName, nil
+		}
+	}
+
+	return "", errors.New("please provide either major.minor or major/minor for directory structure")
+}
+
+func releaseDirNames(release string) []string {
+	names := []string{release}
+
+	parts := strings.Split(release, ".")
+	if len(parts) == 2 {
+		names = append(names, filepath.Join(parts[0], parts[1]))
+	}
+
+	return names
+}
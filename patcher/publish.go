@@ -0,0 +1,118 @@
+package patcher
+
+import (
+	"fmt"
+	"sort"
+)
+
+type PublishOptions struct {
+	Tag             bool
+	TagPrefix       string
+	Remote          string
+	SubmoduleRemote string
+}
+
+type SubmoduleCommit struct {
+	Path string
+	SHA  string
+}
+
+type publisher interface {
+	Tag(name, ref string) error
+	Push(remote string, refspecs []string) error
+	SubmoduleCommits(ref, path string) ([]SubmoduleCommit, error)
+	PushSubmodule(path, remote, refspec string) error
+}
+
+type Publish struct {
+	repo    publisher
+	options PublishOptions
+}
+
+func NewPublish(repo publisher, options PublishOptions) Publish {
+	return Publish{
+		repo:    repo,
+		options: options,
+	}
+}
+
+func (p Publish) Checkpoints(checkpoints []Checkpoint) error {
+	for _, checkpoint := range checkpoints {
+		err := p.Checkpoint(checkpoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p Publish) Checkpoint(checkpoint Checkpoint) error {
+	branch := checkpoint.FinalBranch
+	refspecs := []string{fmt.Sprintf("refs/heads/%s:refs/heads/%s", branch, branch)}
+
+	if p.options.Tag {
+		tag := p.options.TagPrefix + branch
+
+		err := p.repo.Tag(tag, branch)
+		if err != nil {
+			return fmt.Errorf("could not tag %s as %s: %s", branch, tag, err)
+		}
+
+		refspecs = append(refspecs, fmt.Sprintf("refs/tags/%s:refs/tags/%s", tag, tag))
+	}
+
+	if p.options.Remote == "" {
+		return nil
+	}
+
+	pushed := map[string]bool{}
+	for _, path := range patchedSubmodules(checkpoint) {
+		commits, err := p.repo.SubmoduleCommits(branch, path)
+		if err != nil {
+			return err
+		}
+
+		for i := len(commits) - 1; i >= 0; i-- {
+			commit := commits[i]
+			if pushed[commit.Path] {
+				continue
+			}
+
+			err = p.repo.PushSubmodule(commit.Path, p.options.SubmoduleRemote, fmt.Sprintf("%s:refs/heads/%s", commit.SHA, branch))
+			if err != nil {
+				return fmt.Errorf("could not push submodule %q to %s: %s", commit.Path, p.options.SubmoduleRemote, err)
+			}
+
+			pushed[commit.Path] = true
+		}
+	}
+
+	err := p.repo.Push(p.options.Remote, refspecs)
+	if err != nil {
+		return fmt.Errorf("could not push %s to %s: %s", branch, p.options.Remote, err)
+	}
+
+	return nil
+}
+
+func patchedSubmodules(checkpoint Checkpoint) []string {
+	patched := map[string]bool{}
+	for _, change := range checkpoint.Changes {
+		for path := range change.SubmodulePatches {
+			patched[path] = true
+		}
+
+		for _, path := range change.SubmoduleRemovals {
+			delete(patched, path)
+		}
+	}
+
+	var paths []string
+	for path := range patched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
@@ -0,0 +1,162 @@
+package patcher_test
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf/knit/patcher"
+	"github.com/pivotal-cf/knit/patcher/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Publish", func() {
+	var (
+		repo       *fakes.Publisher
+		options    patcher.PublishOptions
+		checkpoint patcher.Checkpoint
+	)
+
+	BeforeEach(func() {
+		repo = &fakes.Publisher{}
+		repo.SubmoduleCommitsCall.Returns.SHAs = map[string]string{
+			"src/patched":       "patched-sha",
+			"src/other-patched": "other-patched-sha",
+		}
+
+		options = patcher.PublishOptions{
+			Tag:             true,
+			TagPrefix:       "v",
+			Remote:          "release",
+			SubmoduleRemote: "origin",
+		}
+
+		checkpoint = patcher.Checkpoint{
+			Changes: []patcher.Changeset{
+				{
+					SubmodulePatches: map[string][]string{
+						"src/patched": []string{"some.patch"},
+						"src/removed": []string{"other.patch"},
+					},
+				},
+				{
+					Bumps: map[string]string{
+						"src/bumped": "bumped-sha",
+					},
+					SubmodulePatches: map[string][]string{
+						"src/other-patched": []string{"another.patch"},
+					},
+					SubmoduleRemovals: []string{"src/removed"},
+				},
+			},
+			CheckoutRef: "v1",
+			FinalBranch: "1.9.2",
+		}
+	})
+
+	It("tags the final branch", func() {
+		err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(repo.TagCall.Receives.Names).To(Equal([]string{"v1.9.2"}))
+		Expect(repo.TagCall.Receives.Refs).To(Equal([]string{"1.9.2"}))
+	})
+
+	It("pushes the commits of the patched submodules before the branch and the tag", func() {
+		err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(repo.PushSubmoduleCall.Receives.Paths).To(Equal([]string{"src/other-patched", "src/patched"}))
+		Expect(repo.PushSubmoduleCall.Receives.Remotes).To(Equal([]string{"origin", "origin"}))
+		Expect(repo.PushSubmoduleCall.Receives.Refspecs).To(Equal([]string{
+			"other-patched-sha:refs/heads/1.9.2",
+			"patched-sha:refs/heads/1.9.2",
+		}))
+
+		Expect(repo.PushCall.Receives.Remote).To(Equal("release"))
+		Expect(repo.PushCall.Receives.Refspecs).To(Equal([]string{
+			"refs/heads/1.9.2:refs/heads/1.9.2",
+			"refs/tags/v1.9.2:refs/tags/v1.9.2",
+		}))
+
+		Expect(repo.Calls).To(Equal([]string{"Tag", "SubmoduleCommits", "PushSubmodule", "SubmoduleCommits", "PushSubmodule", "Push"}))
+	})
+
+	Context("when a patched submodule is nested in another submodule", func() {
+		BeforeEach(func() {
+			checkpoint.Changes[1].SubmodulePatches["src/patched/src/nested"] = []string{"nested.patch"}
+			repo.SubmoduleCommitsCall.Returns.Commits = map[string][]patcher.SubmoduleCommit{
+				"src/patched/src/nested": {
+					{Path: "src/patched", SHA: "patched-sha"},
+					{Path: "src/patched/src/nested", SHA: "nested-sha"},
+				},
+			}
+		})
+
+		It("pushes the commit of every submodule on the way to it once", func() {
+			err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.PushSubmoduleCall.Receives.Paths).To(Equal([]string{"src/other-patched", "src/patched", "src/patched/src/nested"}))
+			Expect(repo.PushSubmoduleCall.Receives.Refspecs).To(Equal([]string{
+				"other-patched-sha:refs/heads/1.9.2",
+				"patched-sha:refs/heads/1.9.2",
+				"nested-sha:refs/heads/1.9.2",
+			}))
+		})
+	})
+
+	Context("when tagging is disabled", func() {
+		It("only pushes the branch", func() {
+			options.Tag = false
+
+			err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.TagCall.Receives.Names).To(BeEmpty())
+			Expect(repo.PushCall.Receives.Refspecs).To(Equal([]string{"refs/heads/1.9.2:refs/heads/1.9.2"}))
+		})
+	})
+
+	Context("when there is no remote", func() {
+		It("only tags the final branch", func() {
+			options.Remote = ""
+
+			err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(repo.Calls).To(Equal([]string{"Tag"}))
+		})
+	})
+
+	Context("when an error occurs", func() {
+		Context("when tagging fails", func() {
+			It("returns an error", func() {
+				repo.TagCall.Returns.Error = errors.New("tag exists")
+
+				err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+				Expect(err).To(MatchError("could not tag 1.9.2 as v1.9.2: tag exists"))
+			})
+		})
+
+		Context("when pushing a submodule fails", func() {
+			It("does not push the branch", func() {
+				repo.PushSubmoduleCall.Returns.Error = errors.New("permission denied")
+
+				err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+				Expect(err).To(MatchError(`could not push submodule "src/other-patched" to origin: permission denied`))
+
+				Expect(repo.PushCall.Receives.Refspecs).To(BeEmpty())
+			})
+		})
+
+		Context("when pushing the branch fails", func() {
+			It("returns an error", func() {
+				repo.PushCall.Returns.Error = errors.New("rejected")
+
+				err := patcher.NewPublish(repo, options).Checkpoint(checkpoint)
+				Expect(err).To(MatchError("could not push 1.9.2 to release: rejected"))
+			})
+		})
+	})
+})
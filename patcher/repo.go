@@ -6,20 +6,35 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	modulePrefix          = "path = "
+	gitlinkMode           = "160000"
 	submoduleMessageRegex = `^.*is in submodule '(.*)'`
 )
 
 type commandRunner interface {
 	Run(command Command) (err error)
 	CombinedOutput(command Command) ([]byte, error)
+	Output(command Command) ([]byte, error)
 }
 
 type Repo struct {
+	CommitMessages         CommitMessages
+	CommitMessageOverrides CommitMessages
+	Reproducible           bool
+	CommitDate             string
+	FetchJobs              int
+	SubmoduleJobs          int
+	FetchDepth             int
+	Filter                 string
+	LocalForks             bool
+
 	runner         commandRunner
 	repo           string
 	committerName  string
@@ -35,29 +50,39 @@ func NewRepo(commandRunner commandRunner, repo string, committerName, committerE
 	}
 }
 
+func (r *Repo) SetCommitMessages(messages CommitMessages) {
+	r.CommitMessages = messages.Merge(r.CommitMessageOverrides)
+}
+
 func (r Repo) Checkout(checkoutRef string) error {
 	commands := []Command{
 		Command{
+			Step: "Checkout",
 			Args: []string{"checkout", checkoutRef},
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "Checkout",
 			Args: []string{"clean", "-ffd"},
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "Checkout",
 			Args: []string{"submodule", "init"},
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "Checkout",
 			Args: []string{"submodule", "foreach", "--recursive", "git submodule sync"},
 			Dir:  r.repo,
 		},
 		Command{
-			Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
+			Step: "Checkout",
+			Args: r.submoduleUpdateArgs(),
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "Checkout",
 			Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 			Dir:  r.repo,
 		},
@@ -73,7 +98,13 @@ func (r Repo) Checkout(checkoutRef string) error {
 }
 
 func (r Repo) ApplyPatch(patch string) error {
+	env, err := r.commitDate("ApplyPatch", r.repo)
+	if err != nil {
+		return err
+	}
+
 	command := Command{
+		Step: "ApplyPatch",
 		Args: []string{
 			"-c", fmt.Sprintf("user.name=%s", r.committerName),
 			"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
@@ -81,9 +112,10 @@ func (r Repo) ApplyPatch(patch string) error {
 			patch,
 		},
 		Dir: r.repo,
+		Env: env,
 	}
 
-	err := r.runner.Run(command)
+	err = r.runner.Run(command)
 	if err != nil {
 		return err
 	}
@@ -95,6 +127,19 @@ func (r Repo) AddSubmodule(path, url, ref, branch string) error {
 	var submoduleAddArgs []string
 	pathToSubmodule := filepath.Join(r.repo, path)
 
+	message, err := r.commitMessage("AddSubmodule", "addition", r.CommitMessages.Addition, fmt.Sprintf("Knit addition of %s", path), CommitMessageData{
+		Path:   path,
+		NewSHA: ref,
+	})
+	if err != nil {
+		return err
+	}
+
+	env, err := r.commitDate("AddSubmodule", r.repo)
+	if err != nil {
+		return err
+	}
+
 	if branch != "" {
 		submoduleAddArgs = []string{"submodule", "add", "--force", "-b", branch, url, path}
 	} else {
@@ -103,42 +148,51 @@ func (r Repo) AddSubmodule(path, url, ref, branch string) error {
 
 	commands := []Command{
 		Command{
+			Step: "AddSubmodule",
 			Args: submoduleAddArgs,
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "AddSubmodule",
 			Args: []string{"checkout", ref},
 			Dir:  pathToSubmodule,
 		},
 		Command{
+			Step: "AddSubmodule",
 			Args: []string{"submodule", "foreach", "--recursive", "git submodule sync"},
 			Dir:  pathToSubmodule,
 		},
 		Command{
-			Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
+			Step: "AddSubmodule",
+			Args: r.submoduleUpdateArgs(),
 			Dir:  pathToSubmodule,
 		},
 		Command{
+			Step: "AddSubmodule",
 			Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "AddSubmodule",
 			Args: []string{"clean", "-ffd"},
 			Dir:  pathToSubmodule,
 		},
 		Command{
+			Step: "AddSubmodule",
 			Args: []string{"add", "-A", path},
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "AddSubmodule",
 			Args: []string{
 				"-c", fmt.Sprintf("user.name=%s", r.committerName),
 				"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
 				"commit",
-				"-m", fmt.Sprintf("Knit addition of %s", path),
+				"-m", message,
 				"--no-verify",
 			},
 			Dir: r.repo,
+			Env: env,
 		},
 	}
 
@@ -152,27 +206,50 @@ func (r Repo) AddSubmodule(path, url, ref, branch string) error {
 }
 
 func (r Repo) RemoveSubmodule(path string) error {
+	data := CommitMessageData{Path: path}
+	if r.CommitMessages.Removal != "" {
+		var err error
+		data.OldSHA, err = r.headSHA("RemoveSubmodule", filepath.Join(r.repo, path))
+		if err != nil {
+			return err
+		}
+	}
+
+	message, err := r.commitMessage("RemoveSubmodule", "removal", r.CommitMessages.Removal, fmt.Sprintf("Knit removal of submodule '%s'", path), data)
+	if err != nil {
+		return err
+	}
+
+	env, err := r.commitDate("RemoveSubmodule", r.repo)
+	if err != nil {
+		return err
+	}
+
 	submoduleDeinitArgs := []string{"submodule", "deinit", "-f", path}
 	submoduleRemoveArgs := []string{"rm", "-f", path}
 
 	commands := []Command{
 		Command{
+			Step: "RemoveSubmodule",
 			Args: submoduleDeinitArgs,
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "RemoveSubmodule",
 			Args: submoduleRemoveArgs,
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "RemoveSubmodule",
 			Args: []string{
 				"-c", fmt.Sprintf("user.name=%s", r.committerName),
 				"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
 				"commit",
-				"-m", fmt.Sprintf("Knit removal of submodule '%s'", path),
+				"-m", message,
 				"--no-verify",
 			},
 			Dir: r.repo,
+			Env: env,
 		},
 	}
 
@@ -189,71 +266,115 @@ func (r Repo) BumpSubmodule(path, sha string) error {
 	pathToSubmodule := filepath.Join(r.repo, path)
 	pathToRepo := r.repo
 
+	data := CommitMessageData{Path: path, NewSHA: sha}
+	if r.CommitMessages.Bump != "" {
+		var err error
+		data.OldSHA, err = r.headSHA("BumpSubmodule", pathToSubmodule)
+		if err != nil {
+			return err
+		}
+	}
+
 	re := regexp.MustCompile(`(src/.*)/(src/.*)`)
 	matches := re.FindStringSubmatch(path)
 	if len(matches) == 3 {
 		pathToRepo = filepath.Join(r.repo, matches[1])
 		path = matches[2]
+		data.Path = path
+	}
+
+	message, err := r.commitMessage("BumpSubmodule", "bump", r.CommitMessages.Bump, fmt.Sprintf("Knit bump of %s", path), data)
+	if err != nil {
+		return err
+	}
+
+	env, err := r.commitDate("BumpSubmodule", pathToRepo)
+	if err != nil {
+		return err
+	}
+
+	err = r.fetchSubmodule("BumpSubmodule", pathToSubmodule, sha)
+	if err != nil {
+		return err
 	}
 
 	commands := []Command{
 		Command{
-			Args: []string{"fetch"},
-			Dir:  pathToSubmodule,
-		},
-		Command{
+			Step: "BumpSubmodule",
 			Args: []string{"checkout", sha},
 			Dir:  pathToSubmodule,
 		},
 		Command{
+			Step: "BumpSubmodule",
 			Args: []string{"submodule", "init"},
 			Dir:  pathToSubmodule,
 		},
 		Command{
+			Step: "BumpSubmodule",
 			Args: []string{"submodule", "sync"},
 			Dir:  pathToSubmodule,
 		},
 		Command{
-			Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
+			Step: "BumpSubmodule",
+			Args: r.submoduleUpdateArgs(),
 			Dir:  pathToSubmodule,
 		},
 		Command{
+			Step: "BumpSubmodule",
 			Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "BumpSubmodule",
 			Args: []string{"clean", "-ffd"},
 			Dir:  pathToSubmodule,
 		},
 		Command{
+			Step: "BumpSubmodule",
 			Args: []string{"add", "-A", path},
 			Dir:  pathToRepo,
 		},
 		Command{
+			Step: "BumpSubmodule",
 			Args: []string{
 				"-c", fmt.Sprintf("user.name=%s", r.committerName),
 				"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
 				"commit",
-				"-m", fmt.Sprintf("Knit bump of %s", path),
+				"-m", message,
 				"--no-verify",
 			},
 			Dir: pathToRepo,
+			Env: env,
 		},
 	}
 
 	if len(matches) == 3 {
+		data.Path = matches[1]
+		message, err := r.commitMessage("BumpSubmodule", "bump", r.CommitMessages.Bump, fmt.Sprintf("Knit bump of %s", matches[1]), data)
+		if err != nil {
+			return err
+		}
+
+		env, err := r.commitDate("BumpSubmodule", r.repo)
+		if err != nil {
+			return err
+		}
+
 		commands = append(commands, Command{
+			Step: "BumpSubmodule",
 			Args: []string{"add", "-A", matches[1]},
 			Dir:  r.repo,
 		}, Command{
+			Step: "BumpSubmodule",
 			Args: []string{
 				"-c", fmt.Sprintf("user.name=%s", r.committerName),
 				"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
 				"commit",
-				"-m", fmt.Sprintf("Knit bump of %s", matches[1]),
+				"-m", message,
 				"--no-verify",
 			},
 			Dir: r.repo,
+			Env: env,
 		})
 	}
 
@@ -266,21 +387,97 @@ func (r Repo) BumpSubmodule(path, sha string) error {
 	return nil
 }
 
+func (r Repo) FetchSubmodules(bumps map[string]string) {
+	var paths []string
+	for _, path := range sortSubmodules(bumps) {
+		if !nestedInAny(path, bumps) {
+			paths = append(paths, path)
+		}
+	}
+
+	jobs := r.FetchJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs && i < len(paths); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				path := paths[index]
+				r.fetchSubmodule("FetchSubmodules", filepath.Join(r.repo, path), bumps[path])
+			}
+		}()
+	}
+
+	for index := range paths {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func nestedInAny(path string, bumps map[string]string) bool {
+	for other := range bumps {
+		if strings.HasPrefix(path, other+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r Repo) PatchSubmodule(path, fullPathToPatch string) error {
+	message, err := r.commitMessage("PatchSubmodule", "submodule_patch", r.CommitMessages.SubmodulePatch, fmt.Sprintf("Knit patch of %s", path), CommitMessageData{
+		Path:      path,
+		PatchFile: filepath.Base(fullPathToPatch),
+	})
+	if err != nil {
+		return err
+	}
+
+	env, err := r.commitDate("PatchSubmodule", filepath.Join(r.repo, path))
+	if err != nil {
+		return err
+	}
+
 	applyCommand := Command{
+		Step: "PatchSubmodule",
 		Args: []string{
 			"-c", fmt.Sprintf("user.name=%s", r.committerName),
 			"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
 			"am", fullPathToPatch,
 		},
 		Dir: filepath.Join(r.repo, path),
+		Env: env,
 	}
 
 	if err := r.runner.Run(applyCommand); err != nil {
 		return err
 	}
 
+	return r.commitSubmodulePatch(path, message)
+}
+
+func (r Repo) CommitSubmodulePatch(path, fullPathToPatch string) error {
+	message, err := r.commitMessage("PatchSubmodule", "submodule_patch", r.CommitMessages.SubmodulePatch, fmt.Sprintf("Knit patch of %s", path), CommitMessageData{
+		Path:      path,
+		PatchFile: filepath.Base(fullPathToPatch),
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.commitSubmodulePatch(path, message)
+}
+
+func (r Repo) commitSubmodulePatch(path, message string) error {
 	addCommand := Command{
+		Step: "PatchSubmodule",
 		Args: []string{"add", "-A", path},
 		Dir:  r.repo,
 	}
@@ -290,12 +487,19 @@ func (r Repo) PatchSubmodule(path, fullPathToPatch string) error {
 		submodulePath := re.FindStringSubmatch(string(output))[1]
 		absoluteSubmodulePath := filepath.Join(r.repo, submodulePath)
 
+		env, err := r.commitDate("PatchSubmodule", absoluteSubmodulePath)
+		if err != nil {
+			return err
+		}
+
 		commands := []Command{
 			Command{
+				Step: "PatchSubmodule",
 				Args: []string{"add", "-A", "."},
 				Dir:  absoluteSubmodulePath,
 			},
 			Command{
+				Step: "PatchSubmodule",
 				Args: []string{
 					"-c", fmt.Sprintf("user.name=%s", r.committerName),
 					"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
@@ -304,6 +508,7 @@ func (r Repo) PatchSubmodule(path, fullPathToPatch string) error {
 					"--no-verify",
 				},
 				Dir: absoluteSubmodulePath,
+				Env: env,
 			},
 		}
 
@@ -314,20 +519,28 @@ func (r Repo) PatchSubmodule(path, fullPathToPatch string) error {
 		}
 	}
 
+	env, err := r.commitDate("PatchSubmodule", r.repo)
+	if err != nil {
+		return err
+	}
+
 	commitCommands := []Command{
 		Command{
+			Step: "PatchSubmodule",
 			Args: []string{"add", "-A", "."},
 			Dir:  r.repo,
 		},
 		Command{
+			Step: "PatchSubmodule",
 			Args: []string{
 				"-c", fmt.Sprintf("user.name=%s", r.committerName),
 				"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
 				"commit",
-				"-m", fmt.Sprintf("Knit patch of %s", path),
+				"-m", message,
 				"--no-verify",
 			},
 			Dir: r.repo,
+			Env: env,
 		},
 	}
 
@@ -340,8 +553,118 @@ func (r Repo) PatchSubmodule(path, fullPathToPatch string) error {
 	return nil
 }
 
+func (r Repo) ForkSubmodule(path, url string) error {
+	pathToSubmodule := filepath.Join(r.repo, path)
+
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "ForkSubmodule",
+		Args: []string{"rev-parse", "--abbrev-ref", "HEAD"},
+		Dir:  r.repo,
+	})
+	if err != nil {
+		return fmt.Errorf("could not determine the current branch: %s", output)
+	}
+
+	branch := fmt.Sprintf("knit/%s", strings.TrimSpace(string(output)))
+
+	sha, err := r.headSHA("ForkSubmodule", pathToSubmodule)
+	if err != nil {
+		return err
+	}
+
+	name, err := r.submoduleName(path)
+	if err != nil {
+		return err
+	}
+
+	var commands []Command
+	if !r.LocalForks {
+		commands = append(commands, Command{
+			Step: "ForkSubmodule",
+			Args: []string{"push", "--force", url, fmt.Sprintf("%s:refs/heads/%s", sha, branch)},
+			Dir:  pathToSubmodule,
+		})
+	}
+
+	commands = append(commands,
+		Command{
+			Step: "ForkSubmodule",
+			Args: []string{"config", "--file", ".gitmodules", fmt.Sprintf("submodule.%s.url", name), url},
+			Dir:  r.repo,
+		},
+		Command{
+			Step: "ForkSubmodule",
+			Args: []string{"add", ".gitmodules"},
+			Dir:  r.repo,
+		},
+	)
+
+	for _, command := range commands {
+		if err := r.runner.Run(command); err != nil {
+			return err
+		}
+	}
+
+	err = r.runner.Run(Command{
+		Step: "ForkSubmodule",
+		Args: []string{"diff", "--cached", "--quiet", "--", ".gitmodules"},
+		Dir:  r.repo,
+	})
+	if err == nil {
+		return nil
+	}
+
+	message, err := r.commitMessage("ForkSubmodule", "fork", r.CommitMessages.Fork, fmt.Sprintf("Knit fork of %s", path), CommitMessageData{
+		Path:   path,
+		NewSHA: sha,
+		URL:    url,
+	})
+	if err != nil {
+		return err
+	}
+
+	env, err := r.commitDate("ForkSubmodule", r.repo)
+	if err != nil {
+		return err
+	}
+
+	return r.runner.Run(Command{
+		Step: "ForkSubmodule",
+		Args: []string{
+			"-c", fmt.Sprintf("user.name=%s", r.committerName),
+			"-c", fmt.Sprintf("user.email=%s", r.committerEmail),
+			"commit",
+			"-m", message,
+			"--no-verify",
+		},
+		Dir: r.repo,
+		Env: env,
+	})
+}
+
+func (r Repo) submoduleName(path string) (string, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "ForkSubmodule",
+		Args: []string{"config", "--file", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`},
+		Dir:  r.repo,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not read .gitmodules: %s", strings.TrimSpace(string(output)))
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) == 2 && fields[1] == path {
+			return strings.TrimSuffix(strings.TrimPrefix(fields[0], "submodule."), ".path"), nil
+		}
+	}
+
+	return "", fmt.Errorf("submodule %q not found in .gitmodules", path)
+}
+
 func (r Repo) CheckoutBranch(name string) error {
 	err := r.runner.Run(Command{
+		Step: "CheckoutBranch",
 		Args: []string{"rev-parse", "--verify", fmt.Sprintf("refs/heads/%s", name)},
 		Dir:  r.repo,
 	})
@@ -350,6 +673,7 @@ func (r Repo) CheckoutBranch(name string) error {
 	}
 
 	err = r.runner.Run(Command{
+		Step: "CheckoutBranch",
 		Args: []string{"checkout", "-b", name},
 		Dir:  r.repo,
 	})
@@ -360,6 +684,145 @@ func (r Repo) CheckoutBranch(name string) error {
 	return nil
 }
 
+func (r Repo) commitMessage(step, name, text, fallback string, data CommitMessageData) (string, error) {
+	if text == "" {
+		return fallback, nil
+	}
+
+	output, err := r.runner.CombinedOutput(Command{
+		Step: step,
+		Args: []string{"rev-parse", "--abbrev-ref", "HEAD"},
+		Dir:  r.repo,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not determine the current branch: %s", output)
+	}
+
+	data.Version = strings.TrimSpace(string(output))
+
+	return renderCommitMessage(name, text, data)
+}
+
+func (r Repo) headSHA(step, dir string) (string, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: step,
+		Args: []string{"rev-parse", "HEAD"},
+		Dir:  dir,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not determine the commit of %s: %s", dir, output)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (r Repo) fetchSubmodule(step, dir, sha string) error {
+	if r.hasCommit(step, dir, sha) {
+		return nil
+	}
+
+	err := r.runner.Run(Command{
+		Step: step,
+		Args: append(append([]string{"fetch"}, r.fetchOptions()...), "origin", sha),
+		Dir:  dir,
+	})
+	if err == nil {
+		return nil
+	}
+
+	err = r.runner.Run(Command{
+		Step: step,
+		Args: append(append([]string{"fetch"}, r.fetchOptions()...), "origin"),
+		Dir:  dir,
+	})
+	if err == nil && r.hasCommit(step, dir, sha) {
+		return nil
+	}
+
+	remote := "origin"
+	output, urlErr := r.runner.CombinedOutput(Command{
+		Step: step,
+		Args: []string{"remote", "get-url", "origin"},
+		Dir:  dir,
+	})
+	if urlErr == nil {
+		remote = fmt.Sprintf("origin (%s)", strings.TrimSpace(string(output)))
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not fetch %s from %s: %s", sha, remote, err)
+	}
+
+	return fmt.Errorf("could not find %s in %s", sha, remote)
+}
+
+func (r Repo) hasCommit(step, dir, sha string) bool {
+	_, err := r.runner.CombinedOutput(Command{
+		Step: step,
+		Args: []string{"cat-file", "-e", fmt.Sprintf("%s^{commit}", sha)},
+		Dir:  dir,
+	})
+
+	return err == nil
+}
+
+func (r Repo) submoduleUpdateArgs() []string {
+	args := []string{"submodule", "update", "--init", "--recursive", "--force"}
+
+	return append(args, submoduleFetchOptions(r.SubmoduleJobs, r.FetchDepth, r.Filter)...)
+}
+
+func (r Repo) fetchOptions() []string {
+	return fetchOptions(r.FetchDepth, r.Filter)
+}
+
+func submoduleFetchOptions(jobs, depth int, filter string) []string {
+	if jobs < 1 {
+		jobs = 4
+	}
+
+	return append([]string{fmt.Sprintf("--jobs=%d", jobs)}, fetchOptions(depth, filter)...)
+}
+
+func fetchOptions(depth int, filter string) []string {
+	var options []string
+
+	if depth > 0 {
+		options = append(options, fmt.Sprintf("--depth=%d", depth))
+	}
+
+	if filter != "" {
+		options = append(options, fmt.Sprintf("--filter=%s", filter))
+	}
+
+	return options
+}
+
+func (r Repo) commitDate(step, dir string) ([]string, error) {
+	if !r.Reproducible {
+		return nil, nil
+	}
+
+	date := r.CommitDate
+	if date == "" {
+		output, err := r.runner.CombinedOutput(Command{
+			Step: step,
+			Args: []string{"log", "-1", "--format=%cd", "--date=raw"},
+			Dir:  dir,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not determine the commit date of %s: %s", dir, strings.TrimSpace(string(output)))
+		}
+
+		date = "@" + strings.TrimSpace(string(output))
+	}
+
+	return []string{
+		fmt.Sprintf("GIT_AUTHOR_DATE=%s", date),
+		fmt.Sprintf("GIT_COMMITTER_DATE=%s", date),
+	}, nil
+}
+
 func (r Repo) submodules() ([]string, error) {
 	modules, err := ioutil.ReadFile(filepath.Join(r.repo, ".gitmodules"))
 	if err != nil {
@@ -393,3 +856,286 @@ func (r Repo) submodules() ([]string, error) {
 
 	return paths, nil
 }
+
+func (r Repo) DeleteBranch(name string) error {
+	return r.runner.Run(Command{
+		Step: "DeleteBranch",
+		Args: []string{"branch", "-D", name},
+		Dir:  r.repo,
+	})
+}
+
+func (r Repo) PatchInProgress(path string) (bool, error) {
+	rebaseApplyPath, err := r.gitPath(filepath.Join(r.repo, path), "rebase-apply")
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(filepath.Join(rebaseApplyPath, "applying"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r Repo) AbortPatch(path string) error {
+	inProgress, err := r.PatchInProgress(path)
+	if err != nil {
+		return err
+	}
+
+	if !inProgress {
+		return nil
+	}
+
+	return r.runner.Run(Command{
+		Step: "AbortPatch",
+		Args: []string{"am", "--abort"},
+		Dir:  filepath.Join(r.repo, path),
+	})
+}
+
+func (r Repo) Head(path string) (string, error) {
+	return r.headSHA("Head", filepath.Join(r.repo, path))
+}
+
+func (r Repo) GitPath(name string) (string, error) {
+	return r.gitPath(r.repo, name)
+}
+
+func (r Repo) gitPath(dir, name string) (string, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "GitPath",
+		Args: []string{"rev-parse", "--git-path", name},
+		Dir:  dir,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not locate %q in the git directory: %s", name, output)
+	}
+
+	path := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	return path, nil
+}
+
+func (r Repo) AddWorktree(path, ref string) error {
+	return r.runner.Run(Command{
+		Step: "AddWorktree",
+		Args: []string{"worktree", "add", "--detach", path, ref},
+		Dir:  r.repo,
+	})
+}
+
+func (r Repo) RemoveWorktree(path string) error {
+	err := os.RemoveAll(path)
+	if err != nil {
+		return err
+	}
+
+	return r.runner.Run(Command{
+		Step: "RemoveWorktree",
+		Args: []string{"worktree", "prune"},
+		Dir:  r.repo,
+	})
+}
+
+func (r Repo) ResolveCommit(ref string) (string, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "ResolveCommit",
+		Args: []string{"rev-parse", "--verify", fmt.Sprintf("%s^{commit}", ref)},
+		Dir:  r.repo,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %q to a commit: %s", ref, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (r Repo) Tag(name, ref string) error {
+	return r.runner.Run(Command{
+		Step: "Tag",
+		Args: []string{"tag", name, ref},
+		Dir:  r.repo,
+	})
+}
+
+func (r Repo) Push(remote string, refspecs []string) error {
+	return r.runner.Run(Command{
+		Step: "Push",
+		Args: append([]string{"push", remote}, refspecs...),
+		Dir:  r.repo,
+	})
+}
+
+func (r Repo) SubmoduleCommits(ref, path string) ([]SubmoduleCommit, error) {
+	var commits []SubmoduleCommit
+
+	dir, prefix, remaining := r.repo, "", path
+	for remaining != "" {
+		gitlink, sha, err := r.gitlink(dir, ref, remaining)
+		if err != nil {
+			return nil, fmt.Errorf("could not find the commit of submodule %q on %s: %s", path, ref, err)
+		}
+
+		prefix = strings.TrimPrefix(prefix+"/"+gitlink, "/")
+		commits = append(commits, SubmoduleCommit{Path: prefix, SHA: sha})
+
+		dir, ref = filepath.Join(dir, gitlink), sha
+		remaining = strings.TrimPrefix(strings.TrimPrefix(remaining, gitlink), "/")
+	}
+
+	return commits, nil
+}
+
+func (r Repo) gitlink(dir, ref, path string) (string, string, error) {
+	var prefixes []string
+	parts := strings.Split(path, "/")
+	for i := range parts {
+		prefixes = append(prefixes, strings.Join(parts[:i+1], "/"))
+	}
+
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "SubmoduleCommit",
+		Args: append([]string{"ls-tree", "-r", "-z", ref, "--"}, prefixes...),
+		Dir:  dir,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+
+	for _, entry := range strings.Split(string(output), "\x00") {
+		fields := strings.SplitN(entry, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		info := strings.Fields(fields[0])
+		entryPath := fields[1]
+		if len(info) == 3 && info[0] == gitlinkMode && (entryPath == path || strings.HasPrefix(path, entryPath+"/")) {
+			return entryPath, info[2], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("%s is not a submodule at %s", path, ref)
+}
+
+func (r Repo) PushSubmodule(path, remote, refspec string) error {
+	return r.runner.Run(Command{
+		Step: "PushSubmodule",
+		Args: []string{"push", remote, refspec},
+		Dir:  filepath.Join(r.repo, path),
+	})
+}
+
+func (r Repo) TrackedFiles() ([]string, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "TrackedFiles",
+		Args: []string{"ls-files", "-z", "--recurse-submodules"},
+		Dir:  r.repo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the files of %s: %s", r.repo, strings.TrimSpace(string(output)))
+	}
+
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+func (r Repo) CommitTime(ref string) (time.Time, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "CommitTime",
+		Args: []string{"log", "-1", "--format=%ct", ref},
+		Dir:  r.repo,
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not determine the commit time of %s: %s", ref, strings.TrimSpace(string(output)))
+	}
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not determine the commit time of %s: %s", ref, err)
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+func (r Repo) DiffTrees(expected, found string) ([]Drift, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "DiffTrees",
+		Args: []string{"diff-tree", "-r", "-z", "--no-renames", expected, found},
+		Dir:  r.repo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not compare %s with %s: %s", expected, found, strings.TrimSpace(string(output)))
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+
+	var drifts []Drift
+	for i := 0; i+1 < len(fields); i += 2 {
+		entry := strings.Fields(strings.TrimPrefix(fields[i], ":"))
+		if len(entry) != 5 {
+			return nil, fmt.Errorf("could not compare %s with %s: unexpected entry %q", expected, found, fields[i])
+		}
+
+		drifts = append(drifts, Drift{
+			Path:      fields[i+1],
+			Expected:  objectSHA(entry[2]),
+			Found:     objectSHA(entry[3]),
+			Submodule: entry[0] == gitlinkMode || entry[1] == gitlinkMode,
+		})
+	}
+
+	return drifts, nil
+}
+
+func (r Repo) SubmoduleTree(path, commit string) (map[string]TreeEntry, error) {
+	output, err := r.runner.CombinedOutput(Command{
+		Step: "SubmoduleTree",
+		Args: []string{"ls-tree", "-r", "-z", commit},
+		Dir:  filepath.Join(r.repo, path),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not find commit %s of submodule %q: %s", commit, path, strings.TrimSpace(string(output)))
+	}
+
+	tree := map[string]TreeEntry{}
+	for _, entry := range strings.Split(string(output), "\x00") {
+		fields := strings.SplitN(entry, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		info := strings.Fields(fields[0])
+		if len(info) != 3 {
+			continue
+		}
+
+		tree[fields[1]] = TreeEntry{Mode: info[0], SHA: info[2]}
+	}
+
+	return tree, nil
+}
+
+func objectSHA(sha string) string {
+	if strings.Trim(sha, "0") == "" {
+		return ""
+	}
+
+	return sha
+}
@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pivotal-cf/knit/patcher"
 	"github.com/pivotal-cf/knit/patcher/fakes"
@@ -51,32 +53,53 @@ var _ = Describe("Repo", func() {
 
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
+					Step: "Checkout",
 					Args: []string{"checkout", "some-ref"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "Checkout",
 					Args: []string{"clean", "-ffd"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "Checkout",
 					Args: []string{"submodule", "init"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "Checkout",
 					Args: []string{"submodule", "foreach", "--recursive", "git submodule sync"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "Checkout",
 					Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "Checkout",
 					Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 					Dir:  repoPath,
 				},
 			}))
 		})
 
+		It("updates the submodules with the configured jobs, depth and filter", func() {
+			r.SubmoduleJobs = 32
+			r.FetchDepth = 1
+			r.Filter = "blob:none"
+
+			err := r.Checkout("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(ContainElement(patcher.Command{
+				Step: "Checkout",
+				Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=32", "--depth=1", "--filter=blob:none"},
+				Dir:  repoPath,
+			}))
+		})
+
 		Context("failure cases", func() {
 			Context("when the checkout fails", func() {
 				It("returns an error", func() {
@@ -95,6 +118,7 @@ var _ = Describe("Repo", func() {
 
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
+					Step: "ApplyPatch",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -105,6 +129,54 @@ var _ = Describe("Repo", func() {
 			}))
 		})
 
+		Context("when the repo is reproducible", func() {
+			BeforeEach(func() {
+				r.Reproducible = true
+			})
+
+			It("dates the commit with the committer date of its parent", func() {
+				runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("1488603967 -0800\n")}
+				runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+				err := r.ApplyPatch("some-dir/something.patch")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+					patcher.Command{
+						Step: "ApplyPatch",
+						Args: []string{"log", "-1", "--format=%cd", "--date=raw"},
+						Dir:  repoPath,
+					},
+				}))
+				Expect(runner.RunCall.Receives.Commands[0].Env).To(Equal([]string{
+					"GIT_AUTHOR_DATE=@1488603967 -0800",
+					"GIT_COMMITTER_DATE=@1488603967 -0800",
+				}))
+			})
+
+			It("dates the commit with the commit date when one is set", func() {
+				r.CommitDate = "@1500000000 +0000"
+
+				err := r.ApplyPatch("some-dir/something.patch")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(runner.CombinedOutputCall.Count).To(Equal(0))
+				Expect(runner.RunCall.Receives.Commands[0].Env).To(Equal([]string{
+					"GIT_AUTHOR_DATE=@1500000000 +0000",
+					"GIT_COMMITTER_DATE=@1500000000 +0000",
+				}))
+			})
+
+			It("returns an error when the date of the parent cannot be read", func() {
+				runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("fatal: bad default revision 'HEAD'")}
+				runner.CombinedOutputCall.Returns.Errors = []error{errors.New("exit status 128")}
+
+				err := r.ApplyPatch("some-dir/something.patch")
+				Expect(err).To(MatchError(fmt.Sprintf("could not determine the commit date of %s: fatal: bad default revision 'HEAD'", repoPath)))
+				Expect(runner.RunCall.Count).To(Equal(0))
+			})
+		})
+
 		Context("when an error occurs", func() {
 			Context("when the command fails", func() {
 				It("returns an error", func() {
@@ -122,34 +194,42 @@ var _ = Describe("Repo", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{"submodule", "add", "--force", "-b", "fake-branch", "some-url", "src/some/path"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{"checkout", "a-sha"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{"submodule", "foreach", "--recursive", "git submodule sync"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{"clean", "-ffd"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{"add", "-A", "src/some/path"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "AddSubmodule",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -168,34 +248,42 @@ var _ = Describe("Repo", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{"submodule", "add", "--force", "some-url", "src/some/path"},
 						Dir:  repoPath,
 					},
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{"checkout", "a-sha"},
 						Dir:  filepath.Join(repoPath, "src", "some", "path"),
 					},
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{"submodule", "foreach", "--recursive", "git submodule sync"},
 						Dir:  filepath.Join(repoPath, "src", "some", "path"),
 					},
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
 						Dir:  filepath.Join(repoPath, "src", "some", "path"),
 					},
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 						Dir:  repoPath,
 					},
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{"clean", "-ffd"},
 						Dir:  filepath.Join(repoPath, "src", "some", "path"),
 					},
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{"add", "-A", "src/some/path"},
 						Dir:  repoPath,
 					},
 					patcher.Command{
+						Step: "AddSubmodule",
 						Args: []string{
 							"-c", fmt.Sprintf("user.name=%s", user),
 							"-c", fmt.Sprintf("user.email=%s", email),
@@ -226,14 +314,17 @@ var _ = Describe("Repo", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
+					Step: "RemoveSubmodule",
 					Args: []string{"submodule", "deinit", "-f", "src/some/path"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "RemoveSubmodule",
 					Args: []string{"rm", "-f", "src/some/path"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "RemoveSubmodule",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -258,44 +349,58 @@ var _ = Describe("Repo", func() {
 	})
 
 	Describe("BumpSubmodule", func() {
+		BeforeEach(func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("fatal: Not a valid object name a-sha^{commit}")}
+			runner.CombinedOutputCall.Returns.Errors = []error{errors.New("exit status 128")}
+		})
+
 		It("bumps the given submodule to the provided sha", func() {
 			err := r.BumpSubmodule("src/some/path", "a-sha")
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
-					Args: []string{"fetch"},
+					Step: "BumpSubmodule",
+					Args: []string{"fetch", "origin", "a-sha"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"checkout", "a-sha"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "init"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "sync"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"clean", "-ffd"},
 					Dir:  filepath.Join(repoPath, "src", "some", "path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"add", "-A", "src/some/path"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -314,38 +419,47 @@ var _ = Describe("Repo", func() {
 
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
-					Args: []string{"fetch"},
+					Step: "BumpSubmodule",
+					Args: []string{"fetch", "origin", "a-sha"},
 					Dir:  filepath.Join(repoPath, "src/some/path", "src/some/other/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"checkout", "a-sha"},
 					Dir:  filepath.Join(repoPath, "src/some/path", "src/some/other/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "init"},
 					Dir:  filepath.Join(repoPath, "src/some/path", "src/some/other/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "sync"},
 					Dir:  filepath.Join(repoPath, "src/some/path", "src/some/other/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "update", "--init", "--recursive", "--force", "--jobs=4"},
 					Dir:  filepath.Join(repoPath, "src/some/path", "src/some/other/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"submodule", "foreach", "--recursive", "git clean -ffd"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"clean", "-ffd"},
 					Dir:  filepath.Join(repoPath, "src/some/path", "src/some/other/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"add", "-A", "src/some/other/path"},
 					Dir:  filepath.Join(repoPath, "src/some/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -356,10 +470,12 @@ var _ = Describe("Repo", func() {
 					Dir: filepath.Join(repoPath, "src/some/path"),
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{"add", "-A", "src/some/path"},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "BumpSubmodule",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -372,10 +488,90 @@ var _ = Describe("Repo", func() {
 			}))
 		})
 
+		It("does not fetch a commit that is already in the submodule", func() {
+			runner.CombinedOutputCall.Returns.Outputs = nil
+			runner.CombinedOutputCall.Returns.Errors = nil
+
+			err := r.BumpSubmodule("src/some/path", "a-sha")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "BumpSubmodule",
+					Args: []string{"cat-file", "-e", "a-sha^{commit}"},
+					Dir:  filepath.Join(repoPath, "src", "some", "path"),
+				},
+			}))
+			Expect(runner.RunCall.Receives.Commands[0].Args).To(Equal([]string{"checkout", "a-sha"}))
+		})
+
+		Context("when the commit cannot be fetched on its own", func() {
+			var (
+				fetchErrors []error
+				found       bool
+			)
+
+			BeforeEach(func() {
+				fetchErrors = []error{errors.New("not our ref"), nil}
+				found = true
+
+				runner.RunCall.Stub = func(command patcher.Command) error {
+					if command.Args[0] != "fetch" {
+						return nil
+					}
+
+					err := fetchErrors[0]
+					fetchErrors = fetchErrors[1:]
+					return err
+				}
+
+				commitChecks := 0
+				runner.CombinedOutputCall.Stub = func(command patcher.Command) ([]byte, error) {
+					switch command.Args[0] {
+					case "cat-file":
+						commitChecks++
+						if commitChecks > 1 && found {
+							return nil, nil
+						}
+
+						return []byte("fatal: Not a valid object name"), errors.New("exit status 128")
+					case "remote":
+						return []byte("https://example.com/some/path.git\n"), nil
+					}
+
+					return nil, nil
+				}
+			})
+
+			It("fetches every branch of the submodule", func() {
+				err := r.BumpSubmodule("src/some/path", "a-sha")
+				Expect(err).NotTo(HaveOccurred())
+
+				commands := runner.RunCall.Receives.Commands
+				Expect(commands[0].Args).To(Equal([]string{"fetch", "origin", "a-sha"}))
+				Expect(commands[1].Args).To(Equal([]string{"fetch", "origin"}))
+				Expect(commands[2].Args).To(Equal([]string{"checkout", "a-sha"}))
+			})
+
+			It("names the remote and the commit when the commit is still missing", func() {
+				found = false
+
+				err := r.BumpSubmodule("src/some/path", "a-sha")
+				Expect(err).To(MatchError("could not find a-sha in origin (https://example.com/some/path.git)"))
+			})
+
+			It("names the remote and the commit when the submodule cannot be fetched", func() {
+				fetchErrors = []error{errors.New("not our ref"), errors.New("could not resolve host")}
+
+				err := r.BumpSubmodule("src/some/path", "a-sha")
+				Expect(err).To(MatchError("could not fetch a-sha from origin (https://example.com/some/path.git): could not resolve host"))
+			})
+		})
+
 		Context("when an error occurs", func() {
 			Context("when the command fails", func() {
 				It("returns an error", func() {
-					runner.RunCall.Returns.Errors = []error{errors.New("meow")}
+					runner.RunCall.Returns.Errors = []error{nil, errors.New("meow")}
 					err := r.BumpSubmodule("src/some/path", "a-sha")
 					Expect(err).To(MatchError("meow"))
 				})
@@ -383,6 +579,323 @@ var _ = Describe("Repo", func() {
 		})
 	})
 
+	Describe("FetchSubmodules", func() {
+		var bumps map[string]string
+
+		BeforeEach(func() {
+			bumps = map[string]string{
+				"src/module-one":   "one-sha",
+				"src/module-two":   "two-sha",
+				"src/module-three": "three-sha",
+			}
+
+			runner.CombinedOutputCall.Stub = func(command patcher.Command) ([]byte, error) {
+				if command.Dir == filepath.Join(repoPath, "src", "module-two") {
+					return nil, nil
+				}
+
+				return []byte("fatal: Not a valid object name"), errors.New("exit status 128")
+			}
+		})
+
+		It("fetches every submodule that is missing its commit", func() {
+			r.FetchSubmodules(bumps)
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "FetchSubmodules",
+					Args: []string{"cat-file", "-e", "one-sha^{commit}"},
+					Dir:  filepath.Join(repoPath, "src", "module-one"),
+				},
+				patcher.Command{
+					Step: "FetchSubmodules",
+					Args: []string{"cat-file", "-e", "three-sha^{commit}"},
+					Dir:  filepath.Join(repoPath, "src", "module-three"),
+				},
+				patcher.Command{
+					Step: "FetchSubmodules",
+					Args: []string{"cat-file", "-e", "two-sha^{commit}"},
+					Dir:  filepath.Join(repoPath, "src", "module-two"),
+				},
+			}))
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "FetchSubmodules",
+					Args: []string{"fetch", "origin", "one-sha"},
+					Dir:  filepath.Join(repoPath, "src", "module-one"),
+				},
+				patcher.Command{
+					Step: "FetchSubmodules",
+					Args: []string{"fetch", "origin", "three-sha"},
+					Dir:  filepath.Join(repoPath, "src", "module-three"),
+				},
+			}))
+		})
+
+		It("fetches the submodules in parallel with the fetch jobs", func() {
+			r.FetchJobs = 3
+
+			started := make(chan string, 3)
+			release := make(chan struct{})
+			runner.RunCall.Stub = func(command patcher.Command) error {
+				started <- command.Dir
+				<-release
+				return nil
+			}
+			runner.CombinedOutputCall.Stub = func(command patcher.Command) ([]byte, error) {
+				return nil, errors.New("exit status 128")
+			}
+
+			done := make(chan struct{})
+			go func() {
+				r.FetchSubmodules(bumps)
+				close(done)
+			}()
+
+			for range bumps {
+				Eventually(started).Should(Receive())
+			}
+			close(release)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("fetches with the configured depth and filter", func() {
+			r.FetchDepth = 1
+			r.Filter = "blob:none"
+
+			r.FetchSubmodules(map[string]string{"src/module-one": "one-sha"})
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "FetchSubmodules",
+					Args: []string{"fetch", "--depth=1", "--filter=blob:none", "origin", "one-sha"},
+					Dir:  filepath.Join(repoPath, "src", "module-one"),
+				},
+			}))
+		})
+
+		It("keeps fetching the other submodules when one cannot be fetched", func() {
+			runner.RunCall.Stub = func(command patcher.Command) error {
+				if command.Dir == filepath.Join(repoPath, "src", "module-one") {
+					return errors.New("could not resolve host")
+				}
+
+				return nil
+			}
+
+			r.FetchSubmodules(bumps)
+
+			var fetched []string
+			for _, command := range runner.RunCall.Receives.Commands {
+				fetched = append(fetched, command.Dir)
+			}
+			Expect(fetched).To(ContainElement(filepath.Join(repoPath, "src", "module-three")))
+		})
+
+		It("leaves submodules nested in another bumped submodule to the bump", func() {
+			r.FetchSubmodules(map[string]string{
+				"src/module-one":            "one-sha",
+				"src/module-one/src/nested": "nested-sha",
+			})
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "FetchSubmodules",
+					Args: []string{"cat-file", "-e", "one-sha^{commit}"},
+					Dir:  filepath.Join(repoPath, "src", "module-one"),
+				},
+			}))
+		})
+	})
+
+	Describe("CommitMessages", func() {
+		BeforeEach(func() {
+			runner.CombinedOutputCall.Stub = func(command patcher.Command) ([]byte, error) {
+				switch strings.Join(command.Args, " ") {
+				case "rev-parse --abbrev-ref HEAD":
+					return []byte("1.9.2\n"), nil
+				case "rev-parse HEAD":
+					return []byte("old-sha\n"), nil
+				}
+
+				return nil, nil
+			}
+		})
+
+		It("renders the bump template", func() {
+			r.CommitMessages.Bump = "Bump {{.Path}} from {{.OldSHA}} to {{.NewSHA}} for {{.Version}} [#123]"
+
+			err := r.BumpSubmodule("src/some/path", "a-sha")
+			Expect(err).NotTo(HaveOccurred())
+
+			commands := runner.RunCall.Receives.Commands
+			Expect(commands[len(commands)-1].Args).To(ContainElement("Bump src/some/path from old-sha to a-sha for 1.9.2 [#123]"))
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(ContainElement(patcher.Command{
+				Step: "BumpSubmodule",
+				Args: []string{"rev-parse", "HEAD"},
+				Dir:  filepath.Join(repoPath, "src/some/path"),
+			}))
+		})
+
+		It("renders the addition template", func() {
+			r.CommitMessages.Addition = "Add {{.Path}} at {{.NewSHA}} for {{.Version}}"
+
+			err := r.AddSubmodule("src/some/path", "some-url", "a-sha", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			commands := runner.RunCall.Receives.Commands
+			Expect(commands[len(commands)-1].Args).To(ContainElement("Add src/some/path at a-sha for 1.9.2"))
+		})
+
+		It("renders the removal template", func() {
+			r.CommitMessages.Removal = "Remove {{.Path}} (was {{.OldSHA}})"
+
+			err := r.RemoveSubmodule("src/some/path")
+			Expect(err).NotTo(HaveOccurred())
+
+			commands := runner.RunCall.Receives.Commands
+			Expect(commands[len(commands)-1].Args).To(ContainElement("Remove src/some/path (was old-sha)"))
+		})
+
+		It("renders the submodule patch template", func() {
+			r.CommitMessages.SubmodulePatch = "Patch {{.Path}} with {{.PatchFile}}"
+
+			err := r.PatchSubmodule("src/some/path", "/full/submodule/some.patch")
+			Expect(err).NotTo(HaveOccurred())
+
+			commands := runner.RunCall.Receives.Commands
+			Expect(commands[len(commands)-1].Args).To(ContainElement("Patch src/some/path with some.patch"))
+		})
+
+		Context("when the template refers to an unknown field", func() {
+			It("returns an error", func() {
+				r.CommitMessages.Bump = "{{.Ticket}}"
+
+				err := r.BumpSubmodule("src/some/path", "a-sha")
+				Expect(err).To(MatchError(ContainSubstring("could not render bump commit message")))
+				Expect(runner.RunCall.Receives.Commands).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("ForkSubmodule", func() {
+		BeforeEach(func() {
+			runner.CombinedOutputCall.Stub = func(command patcher.Command) ([]byte, error) {
+				switch command.Args[0] {
+				case "rev-parse":
+					if command.Args[1] == "--abbrev-ref" {
+						return []byte("1.9.2\n"), nil
+					}
+
+					return []byte("abcde12345\n"), nil
+				default:
+					return []byte("submodule.other.path src/other\nsubmodule.some-sub.path src/some-sub\n"), nil
+				}
+			}
+		})
+
+		It("pushes the submodule to a knit branch on the fork and commits the new url", func() {
+			runner.RunCall.Stub = func(command patcher.Command) error {
+				if command.Args[0] == "diff" {
+					return errors.New("exit status 1")
+				}
+
+				return nil
+			}
+
+			err := r.ForkSubmodule("src/some-sub", "https://example.com/fork.git")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "ForkSubmodule",
+					Args: []string{"push", "--force", "https://example.com/fork.git", "abcde12345:refs/heads/knit/1.9.2"},
+					Dir:  filepath.Join(repoPath, "src/some-sub"),
+				},
+				patcher.Command{
+					Step: "ForkSubmodule",
+					Args: []string{"config", "--file", ".gitmodules", "submodule.some-sub.url", "https://example.com/fork.git"},
+					Dir:  repoPath,
+				},
+				patcher.Command{
+					Step: "ForkSubmodule",
+					Args: []string{"add", ".gitmodules"},
+					Dir:  repoPath,
+				},
+				patcher.Command{
+					Step: "ForkSubmodule",
+					Args: []string{"diff", "--cached", "--quiet", "--", ".gitmodules"},
+					Dir:  repoPath,
+				},
+				patcher.Command{
+					Step: "ForkSubmodule",
+					Args: []string{
+						"-c", "user.name=testbot",
+						"-c", "user.email=foo@example.com",
+						"commit",
+						"-m", "Knit fork of src/some-sub",
+						"--no-verify",
+					},
+					Dir: repoPath,
+				},
+			}))
+		})
+
+		It("leaves the remote of the submodule pointing upstream", func() {
+			err := r.ForkSubmodule("src/some-sub", "https://example.com/fork.git")
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, command := range runner.RunCall.Receives.Commands {
+				Expect(command.Args).NotTo(ContainElement("sync"))
+				Expect(command.Args).NotTo(ContainElement("set-url"))
+			}
+		})
+
+		Context("when forks are only recorded locally", func() {
+			It("points .gitmodules at the fork without pushing to it", func() {
+				r.LocalForks = true
+
+				err := r.ForkSubmodule("src/some-sub", "https://example.com/fork.git")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+					patcher.Command{
+						Step: "ForkSubmodule",
+						Args: []string{"config", "--file", ".gitmodules", "submodule.some-sub.url", "https://example.com/fork.git"},
+						Dir:  repoPath,
+					},
+					patcher.Command{
+						Step: "ForkSubmodule",
+						Args: []string{"add", ".gitmodules"},
+						Dir:  repoPath,
+					},
+					patcher.Command{
+						Step: "ForkSubmodule",
+						Args: []string{"diff", "--cached", "--quiet", "--", ".gitmodules"},
+						Dir:  repoPath,
+					},
+				}))
+			})
+		})
+
+		Context("when .gitmodules already points at the fork", func() {
+			It("does not commit", func() {
+				err := r.ForkSubmodule("src/some-sub", "https://example.com/fork.git")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(runner.RunCall.Receives.Commands).To(HaveLen(4))
+			})
+		})
+
+		Context("when the submodule is not in .gitmodules", func() {
+			It("returns an error", func() {
+				err := r.ForkSubmodule("src/missing", "https://example.com/fork.git")
+				Expect(err).To(MatchError(`submodule "src/missing" not found in .gitmodules`))
+			})
+		})
+	})
+
 	Describe("PatchSubmodule", func() {
 		It("patches a submodule with the proper patch", func() {
 			err := r.PatchSubmodule("src/different/path", "/full/submodule/some.patch")
@@ -390,6 +903,7 @@ var _ = Describe("Repo", func() {
 
 			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
+					Step: "PatchSubmodule",
 					Args: []string{"add", "-A", "src/different/path"},
 					Dir:  repoPath,
 				},
@@ -397,6 +911,7 @@ var _ = Describe("Repo", func() {
 
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
+					Step: "PatchSubmodule",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -406,10 +921,12 @@ var _ = Describe("Repo", func() {
 					Dir: filepath.Join(repoPath, "src", "different/path"),
 				},
 				patcher.Command{
+					Step: "PatchSubmodule",
 					Args: []string{"add", "-A", "."},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "PatchSubmodule",
 					Args: []string{
 						"-c", fmt.Sprintf("user.name=%s", user),
 						"-c", fmt.Sprintf("user.email=%s", email),
@@ -434,6 +951,7 @@ var _ = Describe("Repo", func() {
 
 				Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
 					patcher.Command{
+						Step: "PatchSubmodule",
 						Args: []string{"add", "-A", "src/different/path"},
 						Dir:  repoPath,
 					},
@@ -441,6 +959,7 @@ var _ = Describe("Repo", func() {
 
 				Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 					patcher.Command{
+						Step: "PatchSubmodule",
 						Args: []string{
 							"-c", fmt.Sprintf("user.name=%s", user),
 							"-c", fmt.Sprintf("user.email=%s", email),
@@ -450,10 +969,12 @@ var _ = Describe("Repo", func() {
 						Dir: filepath.Join(repoPath, "src", "different/path"),
 					},
 					patcher.Command{
+						Step: "PatchSubmodule",
 						Args: []string{"add", "-A", "."},
 						Dir:  filepath.Join(repoPath, "src/some/crazy/submodule"),
 					},
 					patcher.Command{
+						Step: "PatchSubmodule",
 						Args: []string{
 							"-c", fmt.Sprintf("user.name=%s", user),
 							"-c", fmt.Sprintf("user.email=%s", email),
@@ -464,10 +985,12 @@ var _ = Describe("Repo", func() {
 						Dir: filepath.Join(repoPath, "src/some/crazy/submodule"),
 					},
 					patcher.Command{
+						Step: "PatchSubmodule",
 						Args: []string{"add", "-A", "."},
 						Dir:  repoPath,
 					},
 					patcher.Command{
+						Step: "PatchSubmodule",
 						Args: []string{
 							"-c", fmt.Sprintf("user.name=%s", user),
 							"-c", fmt.Sprintf("user.email=%s", email),
@@ -492,6 +1015,40 @@ var _ = Describe("Repo", func() {
 		})
 	})
 
+	Describe("CommitSubmodulePatch", func() {
+		It("commits a submodule patch that was applied by hand", func() {
+			err := r.CommitSubmodulePatch("src/different/path", "/full/submodule/some.patch")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "PatchSubmodule",
+					Args: []string{"add", "-A", "src/different/path"},
+					Dir:  repoPath,
+				},
+			}))
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "PatchSubmodule",
+					Args: []string{"add", "-A", "."},
+					Dir:  repoPath,
+				},
+				patcher.Command{
+					Step: "PatchSubmodule",
+					Args: []string{
+						"-c", fmt.Sprintf("user.name=%s", user),
+						"-c", fmt.Sprintf("user.email=%s", email),
+						"commit",
+						"-m", "Knit patch of src/different/path",
+						"--no-verify",
+					},
+					Dir: repoPath,
+				},
+			}))
+		})
+	})
+
 	Describe("CheckoutBranch", func() {
 		It("checks out the desired branch", func() {
 			runner.RunCall.Returns.Errors = []error{errors.New("meow"), nil}
@@ -502,10 +1059,12 @@ var _ = Describe("Repo", func() {
 
 			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
 				patcher.Command{
+					Step: "CheckoutBranch",
 					Args: []string{"rev-parse", "--verify", fmt.Sprintf("refs/heads/%s", branchName)},
 					Dir:  repoPath,
 				},
 				patcher.Command{
+					Step: "CheckoutBranch",
 					Args: []string{"checkout", "-b", branchName},
 					Dir:  repoPath,
 				},
@@ -522,4 +1081,410 @@ var _ = Describe("Repo", func() {
 			})
 		})
 	})
+
+	Describe("DeleteBranch", func() {
+		It("deletes the branch", func() {
+			err := r.DeleteBranch("1.9.2")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "DeleteBranch",
+					Args: []string{"branch", "-D", "1.9.2"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+	})
+
+	Describe("GitPath", func() {
+		It("returns the absolute path of the file inside the git directory", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte(".git/knit-state.json\n")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			path, err := r.GitPath("knit-state.json")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal(filepath.Join(repoPath, ".git", "knit-state.json")))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "GitPath",
+					Args: []string{"rev-parse", "--git-path", "knit-state.json"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+
+		Context("when git fails", func() {
+			It("returns an error", func() {
+				runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("not a git repository")}
+				runner.CombinedOutputCall.Returns.Errors = []error{errors.New("exit status 128")}
+
+				_, err := r.GitPath("knit-state.json")
+				Expect(err).To(MatchError(`could not locate "knit-state.json" in the git directory: not a git repository`))
+			})
+		})
+	})
+
+	Describe("AbortPatch", func() {
+		BeforeEach(func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte(".git/rebase-apply"), []byte(".git/rebase-apply")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil, nil}
+		})
+
+		Context("when git am is in progress", func() {
+			BeforeEach(func() {
+				err := os.MkdirAll(filepath.Join(repoPath, ".git", "rebase-apply"), 0755)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = ioutil.WriteFile(filepath.Join(repoPath, ".git", "rebase-apply", "applying"), []byte{}, 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("aborts it", func() {
+				inProgress, err := r.PatchInProgress("")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(inProgress).To(BeTrue())
+
+				err = r.AbortPatch("")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+					patcher.Command{
+						Step: "AbortPatch",
+						Args: []string{"am", "--abort"},
+						Dir:  repoPath,
+					},
+				}))
+			})
+		})
+
+		Context("when git am is in progress in a submodule", func() {
+			BeforeEach(func() {
+				err := os.MkdirAll(filepath.Join(repoPath, ".git", "modules", "src", "some", "path", "rebase-apply"), 0755)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = ioutil.WriteFile(filepath.Join(repoPath, ".git", "modules", "src", "some", "path", "rebase-apply", "applying"), []byte{}, 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				gitDir := filepath.Join(repoPath, ".git", "modules", "src", "some", "path", "rebase-apply")
+				runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte(gitDir), []byte(gitDir)}
+			})
+
+			It("aborts it in the submodule", func() {
+				inProgress, err := r.PatchInProgress("src/some/path")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(inProgress).To(BeTrue())
+
+				err = r.AbortPatch("src/some/path")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(runner.CombinedOutputCall.Receives.Commands[0]).To(Equal(patcher.Command{
+					Step: "GitPath",
+					Args: []string{"rev-parse", "--git-path", "rebase-apply"},
+					Dir:  filepath.Join(repoPath, "src/some/path"),
+				}))
+
+				Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+					patcher.Command{
+						Step: "AbortPatch",
+						Args: []string{"am", "--abort"},
+						Dir:  filepath.Join(repoPath, "src/some/path"),
+					},
+				}))
+			})
+		})
+
+		Context("when git am is not in progress", func() {
+			It("does nothing", func() {
+				inProgress, err := r.PatchInProgress("")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(inProgress).To(BeFalse())
+
+				err = r.AbortPatch("")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(runner.RunCall.Receives.Commands).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Head", func() {
+		It("returns the commit checked out at the path", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("some-sha\n")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			head, err := r.Head("src/some/path")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(head).To(Equal("some-sha"))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "Head",
+					Args: []string{"rev-parse", "HEAD"},
+					Dir:  filepath.Join(repoPath, "src/some/path"),
+				},
+			}))
+		})
+	})
+
+	Describe("AddWorktree", func() {
+		It("adds a detached worktree at the ref", func() {
+			err := r.AddWorktree("/tmp/some-worktree", "v124")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "AddWorktree",
+					Args: []string{"worktree", "add", "--detach", "/tmp/some-worktree", "v124"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+	})
+
+	Describe("RemoveWorktree", func() {
+		It("deletes the worktree and prunes it", func() {
+			worktree := filepath.Join(repoPath, "some-worktree")
+			err := os.MkdirAll(filepath.Join(worktree, "src"), 0755)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = r.RemoveWorktree(worktree)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(worktree).NotTo(BeADirectory())
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "RemoveWorktree",
+					Args: []string{"worktree", "prune"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+	})
+
+	Describe("ResolveCommit", func() {
+		It("returns the sha of the commit the ref points at", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("abcde12345\n")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			sha, err := r.ResolveCommit("v124")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sha).To(Equal("abcde12345"))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "ResolveCommit",
+					Args: []string{"rev-parse", "--verify", "v124^{commit}"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+
+		Context("when the ref does not exist", func() {
+			It("returns an error", func() {
+				runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("fatal: Needed a single revision\n")}
+				runner.CombinedOutputCall.Returns.Errors = []error{errors.New("exit status 128")}
+
+				_, err := r.ResolveCommit("v999")
+				Expect(err).To(MatchError(`could not resolve "v999" to a commit: fatal: Needed a single revision`))
+			})
+		})
+	})
+
+	Describe("Tag", func() {
+		It("tags the ref", func() {
+			err := r.Tag("v1.9.2", "1.9.2")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "Tag",
+					Args: []string{"tag", "v1.9.2", "1.9.2"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+	})
+
+	Describe("Push", func() {
+		It("pushes the refspecs to the remote", func() {
+			err := r.Push("release", []string{"refs/heads/1.9.2:refs/heads/1.9.2", "refs/tags/v1.9.2:refs/tags/v1.9.2"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "Push",
+					Args: []string{"push", "release", "refs/heads/1.9.2:refs/heads/1.9.2", "refs/tags/v1.9.2:refs/tags/v1.9.2"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+	})
+
+	Describe("SubmoduleCommits", func() {
+		It("returns the sha the ref records for the submodule", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("160000 commit abcde12345\tsrc/some-sub\x00")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			commits, err := r.SubmoduleCommits("1.9.2", "src/some-sub")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commits).To(Equal([]patcher.SubmoduleCommit{{Path: "src/some-sub", SHA: "abcde12345"}}))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "SubmoduleCommit",
+					Args: []string{"ls-tree", "-r", "-z", "1.9.2", "--", "src", "src/some-sub"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+
+		It("resolves a nested submodule through every submodule on the way to it", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{
+				[]byte("100644 blob 1111111111\tsrc/a-file\x00160000 commit outer-sha\tsrc/outer\x00"),
+				[]byte("160000 commit inner-sha\tsrc/inner\x00"),
+			}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil, nil}
+
+			commits, err := r.SubmoduleCommits("1.9.2", "src/outer/src/inner")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commits).To(Equal([]patcher.SubmoduleCommit{
+				{Path: "src/outer", SHA: "outer-sha"},
+				{Path: "src/outer/src/inner", SHA: "inner-sha"},
+			}))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "SubmoduleCommit",
+					Args: []string{"ls-tree", "-r", "-z", "1.9.2", "--", "src", "src/outer", "src/outer/src", "src/outer/src/inner"},
+					Dir:  repoPath,
+				},
+				patcher.Command{
+					Step: "SubmoduleCommit",
+					Args: []string{"ls-tree", "-r", "-z", "outer-sha", "--", "src", "src/inner"},
+					Dir:  filepath.Join(repoPath, "src/outer"),
+				},
+			}))
+		})
+
+		Context("when the path is not a submodule", func() {
+			It("returns an error", func() {
+				runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("100644 blob 1111111111\tsrc/some-sub\x00")}
+				runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+				_, err := r.SubmoduleCommits("1.9.2", "src/some-sub")
+				Expect(err).To(MatchError(`could not find the commit of submodule "src/some-sub" on 1.9.2: src/some-sub is not a submodule at 1.9.2`))
+			})
+		})
+	})
+
+	Describe("PushSubmodule", func() {
+		It("pushes from inside the submodule", func() {
+			err := r.PushSubmodule("src/some-sub", "origin", "abcde12345:refs/heads/1.9.2")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.RunCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "PushSubmodule",
+					Args: []string{"push", "origin", "abcde12345:refs/heads/1.9.2"},
+					Dir:  filepath.Join(repoPath, "src/some-sub"),
+				},
+			}))
+		})
+	})
+
+	Describe("TrackedFiles", func() {
+		It("lists the files of the repository and its submodules", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte(".gitmodules\x00README.md\x00src/sub/file\x00")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			files, err := r.TrackedFiles()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(Equal([]string{".gitmodules", "README.md", "src/sub/file"}))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "TrackedFiles",
+					Args: []string{"ls-files", "-z", "--recurse-submodules"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+	})
+
+	Describe("CommitTime", func() {
+		It("returns the committer date of the ref", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("1488603967\n")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			commitTime, err := r.CommitTime("v124")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commitTime).To(Equal(time.Unix(1488603967, 0).UTC()))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "CommitTime",
+					Args: []string{"log", "-1", "--format=%ct", "v124"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+	})
+
+	Describe("DiffTrees", func() {
+		It("returns every path that differs between the trees", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte(
+				":100644 100644 1111111111111111111111111111111111111111 2222222222222222222222222222222222222222 M\x00file.txt\x00" +
+					":000000 100644 0000000000000000000000000000000000000000 3333333333333333333333333333333333333333 A\x00new file.txt\x00" +
+					":160000 160000 4444444444444444444444444444444444444444 5555555555555555555555555555555555555555 M\x00src/sub\x00",
+			)}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			drifts, err := r.DiffTrees("HEAD", "1.2.1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(Equal([]patcher.Drift{
+				{Path: "file.txt", Expected: "1111111111111111111111111111111111111111", Found: "2222222222222222222222222222222222222222"},
+				{Path: "new file.txt", Found: "3333333333333333333333333333333333333333"},
+				{Path: "src/sub", Expected: "4444444444444444444444444444444444444444", Found: "5555555555555555555555555555555555555555", Submodule: true},
+			}))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "DiffTrees",
+					Args: []string{"diff-tree", "-r", "-z", "--no-renames", "HEAD", "1.2.1"},
+					Dir:  repoPath,
+				},
+			}))
+		})
+
+		It("returns an error when the trees cannot be compared", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("fatal: ambiguous argument 'nope'\n")}
+			runner.CombinedOutputCall.Returns.Errors = []error{errors.New("exit status 128")}
+
+			_, err := r.DiffTrees("HEAD", "nope")
+			Expect(err).To(MatchError("could not compare HEAD with nope: fatal: ambiguous argument 'nope'"))
+		})
+	})
+
+	Describe("SubmoduleTree", func() {
+		It("lists the files and nested submodules of the commit in the submodule", func() {
+			runner.CombinedOutputCall.Returns.Outputs = [][]byte{[]byte("100644 blob main-blob\tmain.go\x00160000 commit nested-sha\tsrc/nested\x00")}
+			runner.CombinedOutputCall.Returns.Errors = []error{nil}
+
+			tree, err := r.SubmoduleTree("src/sub", "commit-sha")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tree).To(Equal(map[string]patcher.TreeEntry{
+				"main.go":    {Mode: "100644", SHA: "main-blob"},
+				"src/nested": {Mode: "160000", SHA: "nested-sha"},
+			}))
+
+			Expect(runner.CombinedOutputCall.Receives.Commands).To(Equal([]patcher.Command{
+				patcher.Command{
+					Step: "SubmoduleTree",
+					Args: []string{"ls-tree", "-r", "-z", "commit-sha"},
+					Dir:  filepath.Join(repoPath, "src", "sub"),
+				},
+			}))
+		})
+	})
 })
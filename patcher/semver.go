@@ -0,0 +1,172 @@
+package patcher
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	numericIdentifierRegexp = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+	identifierRegexp        = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+)
+
+type SemVer struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	Hotfixes   []string
+}
+
+func ParseSemVer(version string) (SemVer, error) {
+	invalid := func(format string, args ...interface{}) (SemVer, error) {
+		return SemVer{}, fmt.Errorf("invalid version %q: %s", version, fmt.Sprintf(format, args...))
+	}
+
+	core := version
+
+	var semver SemVer
+	if i := strings.Index(core, "+"); i >= 0 {
+		semver.Hotfixes = strings.Split(core[i+1:], "+")
+		core = core[:i]
+
+		for _, hotfix := range semver.Hotfixes {
+			if hotfix == "" {
+				return invalid("hotfix must not be empty")
+			}
+		}
+	}
+
+	if i := strings.Index(core, "-"); i >= 0 {
+		semver.PreRelease = core[i+1:]
+		core = core[:i]
+
+		if err := validatePreRelease(semver.PreRelease); err != nil {
+			return invalid("pre-release %s", err)
+		}
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return invalid("expected major.minor.patch")
+	}
+
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		if !numericIdentifierRegexp.MatchString(part) {
+			return invalid("%q is not a number", part)
+		}
+
+		var err error
+		numbers[i], err = strconv.Atoi(part)
+		if err != nil {
+			return invalid("%q is not a number", part)
+		}
+	}
+
+	semver.Major, semver.Minor, semver.Patch = numbers[0], numbers[1], numbers[2]
+
+	return semver, nil
+}
+
+func (v SemVer) String() string {
+	version := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		version += "-" + v.PreRelease
+	}
+
+	for _, hotfix := range v.Hotfixes {
+		version += "+" + hotfix
+	}
+
+	return version
+}
+
+func (v SemVer) Compare(other SemVer) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff != 0 {
+			return sign(diff)
+		}
+	}
+
+	return comparePreReleases(v.PreRelease, other.PreRelease)
+}
+
+func validatePreRelease(preRelease string) error {
+	if preRelease == "" {
+		return errors.New("must not be empty")
+	}
+
+	for _, identifier := range strings.Split(preRelease, ".") {
+		if !identifierRegexp.MatchString(identifier) {
+			return fmt.Errorf("identifier %q must be a non-empty string of [0-9A-Za-z-]", identifier)
+		}
+
+		if isNumeric(identifier) && !numericIdentifierRegexp.MatchString(identifier) {
+			return fmt.Errorf("identifier %q must not have leading zeros", identifier)
+		}
+	}
+
+	return nil
+}
+
+func comparePreReleases(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aIdentifiers := strings.Split(a, ".")
+	bIdentifiers := strings.Split(b, ".")
+
+	for i := 0; i < len(aIdentifiers) && i < len(bIdentifiers); i++ {
+		x, y := aIdentifiers[i], bIdentifiers[i]
+		if x == y {
+			continue
+		}
+
+		xNumeric, yNumeric := isNumeric(x), isNumeric(y)
+		switch {
+		case xNumeric && yNumeric:
+			xNumber, _ := strconv.Atoi(x)
+			yNumber, _ := strconv.Atoi(y)
+			return sign(xNumber - yNumber)
+		case xNumeric:
+			return -1
+		case yNumeric:
+			return 1
+		default:
+			return strings.Compare(x, y)
+		}
+	}
+
+	return sign(len(aIdentifiers) - len(bIdentifiers))
+}
+
+func isNumeric(identifier string) bool {
+	for _, r := range identifier {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return identifier != ""
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
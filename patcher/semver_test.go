@@ -0,0 +1,66 @@
+package patcher_test
+
+import (
+	"github.com/pivotal-cf/knit/patcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SemVer", func() {
+	DescribeTable("ParseSemVer",
+		func(version string, expected patcher.SemVer) {
+			semver, err := patcher.ParseSemVer(version)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(semver).To(Equal(expected))
+			Expect(semver.String()).To(Equal(version))
+		},
+		Entry("a release", "1.7.2", patcher.SemVer{Major: 1, Minor: 7, Patch: 2}),
+		Entry("a pre-release", "1.7.2-rc.1", patcher.SemVer{Major: 1, Minor: 7, Patch: 2, PreRelease: "rc.1"}),
+		Entry("a hotfix", "1.7.2+hot.fix", patcher.SemVer{Major: 1, Minor: 7, Patch: 2, Hotfixes: []string{"hot.fix"}}),
+		Entry("a hotfix of a pre-release", "1.7.2-rc.1+hot-fix", patcher.SemVer{Major: 1, Minor: 7, Patch: 2, PreRelease: "rc.1", Hotfixes: []string{"hot-fix"}}),
+		Entry("stacked hotfixes", "1.7.2+sec-123+perf-9", patcher.SemVer{Major: 1, Minor: 7, Patch: 2, Hotfixes: []string{"sec-123", "perf-9"}}),
+	)
+
+	DescribeTable("ParseSemVer errors",
+		func(version, message string) {
+			_, err := patcher.ParseSemVer(version)
+			Expect(err).To(MatchError(message))
+		},
+		Entry("missing patch", "1.7", `invalid version "1.7": expected major.minor.patch`),
+		Entry("too many parts", "1.7.2.1", `invalid version "1.7.2.1": expected major.minor.patch`),
+		Entry("not a number", "1.x.2", `invalid version "1.x.2": "x" is not a number`),
+		Entry("leading zero", "1.07.2", `invalid version "1.07.2": "07" is not a number`),
+		Entry("empty pre-release", "1.7.2-", `invalid version "1.7.2-": pre-release must not be empty`),
+		Entry("bad pre-release", "1.7.2-rc..1", `invalid version "1.7.2-rc..1": pre-release identifier "" must be a non-empty string of [0-9A-Za-z-]`),
+		Entry("numeric pre-release with leading zero", "1.7.2-01", `invalid version "1.7.2-01": pre-release identifier "01" must not have leading zeros`),
+		Entry("empty hotfix", "1.7.2+", `invalid version "1.7.2+": hotfix must not be empty`),
+		Entry("empty stacked hotfix", "1.7.2+a++b", `invalid version "1.7.2+a++b": hotfix must not be empty`),
+	)
+
+	It("orders versions by semver precedence", func() {
+		ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0"}
+
+		for i := 0; i < len(ordered)-1; i++ {
+			lower, err := patcher.ParseSemVer(ordered[i])
+			Expect(err).NotTo(HaveOccurred())
+
+			higher, err := patcher.ParseSemVer(ordered[i+1])
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lower.Compare(higher)).To(Equal(-1), ordered[i]+" < "+ordered[i+1])
+			Expect(higher.Compare(lower)).To(Equal(1), ordered[i+1]+" > "+ordered[i])
+		}
+	})
+
+	It("ignores the hotfix when comparing", func() {
+		a, err := patcher.ParseSemVer("1.7.2+a")
+		Expect(err).NotTo(HaveOccurred())
+
+		b, err := patcher.ParseSemVer("1.7.2")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(a.Compare(b)).To(Equal(0))
+	})
+})
@@ -0,0 +1,87 @@
+package patcher
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+type State struct {
+	Checkpoint Checkpoint
+	Change     int
+	Step       int
+	PatchPath  string
+	Head       string
+	PatchRef   *PatchRef
+	Pending    []Checkpoint
+	Applied    []Checkpoint
+}
+
+func (s State) Started() bool {
+	return s.Checkpoint.FinalBranch != ""
+}
+
+func (s State) Checkpoints() []Checkpoint {
+	checkpoints := append([]Checkpoint{}, s.Applied...)
+	if s.Started() {
+		checkpoints = append(checkpoints, s.Checkpoint)
+	}
+
+	return append(checkpoints, s.Pending...)
+}
+
+type StateFile struct {
+	path string
+}
+
+func NewStateFile(path string) StateFile {
+	return StateFile{
+		path: path,
+	}
+}
+
+func (s StateFile) Exists() (bool, error) {
+	_, err := os.Stat(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s StateFile) Load() (State, error) {
+	contents, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return State{}, err
+	}
+
+	var state State
+	err = json.Unmarshal(contents, &state)
+	if err != nil {
+		return State{}, err
+	}
+
+	return state, nil
+}
+
+func (s StateFile) Save(state State) error {
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.path, contents, 0644)
+}
+
+func (s StateFile) Clear() error {
+	err := os.Remove(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package patcher_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/knit/patcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StateFile", func() {
+	var (
+		tmpDir    string
+		stateFile patcher.StateFile
+		state     patcher.State
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		stateFile = patcher.NewStateFile(filepath.Join(tmpDir, "knit-state.json"))
+		state = patcher.State{
+			Checkpoint: patcher.Checkpoint{
+				Changes: []patcher.Changeset{
+					{
+						Patches: []string{"patch-1"},
+						Bumps: map[string]string{
+							"src/some-path": "some-sha",
+						},
+					},
+				},
+				CheckoutRef: "v124",
+				FinalBranch: "1.9.2",
+			},
+			Change: 1,
+			Step:   2,
+		}
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(tmpDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("saves and loads the progress", func() {
+		exists, err := stateFile.Exists()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+
+		err = stateFile.Save(state)
+		Expect(err).NotTo(HaveOccurred())
+
+		exists, err = stateFile.Exists()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue())
+
+		loaded, err := stateFile.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(Equal(state))
+	})
+
+	It("clears the progress", func() {
+		err := stateFile.Save(state)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = stateFile.Clear()
+		Expect(err).NotTo(HaveOccurred())
+
+		exists, err := stateFile.Exists()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeFalse())
+
+		err = stateFile.Clear()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("when the state file is not valid", func() {
+		It("returns an error", func() {
+			err := ioutil.WriteFile(filepath.Join(tmpDir, "knit-state.json"), []byte("%%%"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = stateFile.Load()
+			Expect(err).To(MatchError(ContainSubstring("invalid character")))
+		})
+	})
+})
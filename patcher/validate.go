@@ -0,0 +1,207 @@
+package patcher
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type Problem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+func (ps PatchSet) Validate() ([]Problem, error) {
+	releases, err := ps.source.Releases()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for _, release := range releases {
+		problems = append(problems, ps.validateRelease(release)...)
+	}
+
+	return problems, nil
+}
+
+func (ps PatchSet) validateRelease(release string) []Problem {
+	file := ps.source.File(release)
+
+	var problems []Problem
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			File:    file,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	startingVersions, err := ps.source.StartingVersions(release)
+	if err != nil {
+		yamlErrs, ok := err.(YAMLErrors)
+		if !ok {
+			report("%s", err)
+			return problems
+		}
+
+		for _, yamlErr := range yamlErrs {
+			problems = append(problems, Problem{
+				File:    file,
+				Line:    yamlErr.Line,
+				Message: yamlErr.Message,
+			})
+		}
+
+		return problems
+	}
+
+	err = startingVersions.CommitMessages.Validate()
+	if err != nil {
+		report("commit_messages: %s", err)
+	}
+
+	checkPatches := func(context string, patches []string) {
+		for _, patch := range patches {
+			patchPath, err := ps.source.PatchPath(release, patch)
+			if err == nil {
+				_, err = os.Stat(patchPath)
+			}
+
+			if err != nil {
+				report("%s: missing patch file %q", context, patch)
+			}
+		}
+	}
+
+	checkSubmodules := func(context string, submodules map[string]Submodule) {
+		var paths []string
+		for path := range submodules {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			submodule := submodules[path]
+			checkPatches(fmt.Sprintf("%s submodule %q", context, path), submodule.Patches)
+
+			if submodule.Add.URL != "" && submodule.Add.Ref == "" {
+				report("%s: missing ref for new submodule %q", context, path)
+			}
+
+			if submodule.Add.URL != "" && submodule.Remove {
+				report("%s: submodule %q is both added and removed", context, path)
+			}
+
+			if submodule.Fork != "" && submodule.Remove {
+				report("%s: submodule %q is both forked and removed", context, path)
+			}
+		}
+	}
+
+	seenVersions := map[string]bool{}
+	for _, v := range startingVersions.Versions {
+		id := v.id()
+		context := fmt.Sprintf("version %s", id)
+
+		if v.PreRelease != "" {
+			if err := validatePreRelease(v.PreRelease); err != nil {
+				report("%s: invalid pre_release: %s", context, err)
+			}
+		}
+
+		if seenVersions[id] {
+			report("%s: duplicate version", context)
+		}
+		seenVersions[id] = true
+
+		if v.Extends != "" {
+			if _, err := extendedVersion(startingVersions.Versions, v); err != nil {
+				report("%s", err)
+			}
+		} else if v.Ref == "" {
+			report("%s: missing ref", context)
+		}
+
+		checkPatches(context, v.Patches)
+		checkSubmodules(context, v.Submodules)
+
+		var hotfixNames []string
+		for name := range v.Hotfixes {
+			hotfixNames = append(hotfixNames, name)
+		}
+		sort.Strings(hotfixNames)
+
+		for _, name := range hotfixNames {
+			hotfixContext := fmt.Sprintf("%s hotfix %q", context, name)
+
+			var missingDependency bool
+			for _, dependency := range v.Hotfixes[name].DependsOn {
+				if _, ok := v.Hotfixes[dependency]; !ok {
+					report("%s: depends on unknown hotfix %q", hotfixContext, dependency)
+					missingDependency = true
+				}
+			}
+
+			if !missingDependency {
+				if _, err := v.withHotfixes([]string{name}); err != nil {
+					report("%s: %s", hotfixContext, err)
+				}
+			}
+
+			checkPatches(hotfixContext, v.Hotfixes[name].Patches)
+			checkSubmodules(hotfixContext, v.Hotfixes[name].Submodules)
+		}
+	}
+
+	for _, r := range startingVersions.Ranges {
+		context := fmt.Sprintf("range %q", r.Versions)
+
+		constraints, err := parseVersionConstraints(r.Versions)
+		if err != nil {
+			report("%s", err)
+		} else if !rangeReachable(constraints, startingVersions.Versions) {
+			report("%s: matches no version that can be built from the starting versions", context)
+		}
+
+		checkPatches(context, r.Patches)
+		checkSubmodules(context, r.Submodules)
+	}
+
+	return problems
+}
+
+func rangeReachable(constraints []versionConstraint, versions []StartingVersion) bool {
+	if len(versions) == 0 {
+		return false
+	}
+
+	earliest := versions[0].semVer()
+	candidates := []SemVer{}
+	for _, v := range versions {
+		if v.semVer().Compare(earliest) < 0 {
+			earliest = v.semVer()
+		}
+		candidates = append(candidates, v.semVer())
+	}
+
+	for _, constraint := range constraints {
+		candidates = append(candidates, constraint.version, SemVer{Patch: constraint.version.Patch + 1})
+	}
+
+	for _, candidate := range candidates {
+		if candidate.Compare(earliest) >= 0 && inRange(constraints, candidate) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,279 @@
+package patcher_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/knit/patcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	var (
+		patchesRepo string
+		ps          patcher.PatchSet
+	)
+
+	writeFile := func(path, contents string) {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(path, []byte(contents), 0644)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+		patchesRepo, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		writeFile(filepath.Join(patchesRepo, "1.9", "starting-versions.yml"), startingVersionsContent)
+		for _, patch := range []string{"Top-1.patch", "Top-2.patch", "Sub-1.patch", "Sub-2.patch", "Top-88.patch", "Sub-Magic.patch"} {
+			writeFile(filepath.Join(patchesRepo, "1.9", patch), "")
+		}
+
+		ps = patcher.NewPatchSet(patchesRepo)
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(patchesRepo)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports no problems for a valid patch repository", func() {
+		problems, err := ps.Validate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(problems).To(BeEmpty())
+	})
+
+	Context("when the starting versions have problems", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join(patchesRepo, "2", "0", "starting-versions.yml"), `---
+starting_versions:
+- version: 1
+  ref: 'v200'
+  patches:
+  - Missing.patch
+  submodules:
+    "src/new-sub":
+      add:
+        url: fake-url
+    "src/flip-flop":
+      add:
+        url: fake-url
+        ref: fake-sha
+      fork: fake-fork
+      remove: true
+- version: 1
+  ref: 'v201'
+  hotfixes:
+    "urgent":
+      patches:
+      - Missing-Hotfix.patch
+      submodules:
+        "src/some-sub":
+          patches:
+          - Missing-Sub.patch
+`)
+		})
+
+		It("reports every problem", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			file := filepath.Join("2", "0", "starting-versions.yml")
+			Expect(problems).To(Equal([]patcher.Problem{
+				{File: file, Message: `version 1: missing patch file "Missing.patch"`},
+				{File: file, Message: `version 1: submodule "src/flip-flop" is both added and removed`},
+				{File: file, Message: `version 1: submodule "src/flip-flop" is both forked and removed`},
+				{File: file, Message: `version 1: missing ref for new submodule "src/new-sub"`},
+				{File: file, Message: `version 1: duplicate version`},
+				{File: file, Message: `version 1 hotfix "urgent": missing patch file "Missing-Hotfix.patch"`},
+				{File: file, Message: `version 1 hotfix "urgent" submodule "src/some-sub": missing patch file "Missing-Sub.patch"`},
+			}))
+		})
+
+		It("formats problems with the file they were found in", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(problems[0].String()).To(Equal(filepath.Join("2", "0", "starting-versions.yml") + `: version 1: missing patch file "Missing.patch"`))
+		})
+	})
+
+	Context("when the starting versions contain unknown keys", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join(patchesRepo, "1.9", "starting-versions.yml"), `---
+starting_versions:
+- version: 1
+  ref: 'v200'
+  patchs:
+  - Typo.patch
+  submodules:
+    "src/some-sub":
+      patches:
+      - Top-1.patch
+      submodule: typo
+`)
+		})
+
+		It("reports each key with its line number", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			file := filepath.Join("1.9", "starting-versions.yml")
+			Expect(problems).To(Equal([]patcher.Problem{
+				{File: file, Line: 5, Message: `unknown key "patchs" in version`},
+				{File: file, Line: 11, Message: `unknown key "submodule" in submodule`},
+			}))
+			Expect(problems[0].String()).To(Equal(file + `:5: unknown key "patchs" in version`))
+		})
+	})
+
+	Context("when the starting versions have pre-releases", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join(patchesRepo, "1.9", "starting-versions.yml"), `---
+starting_versions:
+- version: 1
+  pre_release: rc.1
+  ref: 'v200'
+- version: 1
+  pre_release: rc.1
+  ref: 'v200'
+- version: 1
+  pre_release: rc.01
+  ref: 'v200'
+- version: 1
+  ref: 'v200'
+`)
+		})
+
+		It("reports invalid and duplicate pre-releases", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			file := filepath.Join("1.9", "starting-versions.yml")
+			Expect(problems).To(Equal([]patcher.Problem{
+				{File: file, Message: `version 1-rc.1: duplicate version`},
+				{File: file, Message: `version 1-rc.01: invalid pre_release: identifier "01" must not have leading zeros`},
+			}))
+		})
+	})
+
+	Context("when versions extend each other or declare ranges", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join(patchesRepo, "1.9", "starting-versions.yml"), `---
+ranges:
+- versions: ">=1, <x"
+  patches:
+  - Missing-Range.patch
+- versions: ">=7, <3"
+- versions: "<1"
+- versions: ">=2, <3"
+starting_versions:
+- version: 1
+- version: 2
+  extends: "3"
+- version: 3
+  extends: "0"
+`)
+		})
+
+		It("reports every problem", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			file := filepath.Join("1.9", "starting-versions.yml")
+			Expect(problems).To(Equal([]patcher.Problem{
+				{File: file, Message: `version 1: missing ref`},
+				{File: file, Message: `version 2: extends "3", which is not an earlier version`},
+				{File: file, Message: `version 3: extends "0", which does not exist`},
+				{File: file, Message: `invalid range ">=1, <x": invalid version "x": expected a patch version such as 3 or 3-rc.1`},
+				{File: file, Message: `range ">=1, <x": missing patch file "Missing-Range.patch"`},
+				{File: file, Message: `range ">=7, <3": matches no version that can be built from the starting versions`},
+				{File: file, Message: `range "<1": matches no version that can be built from the starting versions`},
+			}))
+		})
+	})
+
+	Context("when hotfixes depend on each other", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join(patchesRepo, "1.9", "starting-versions.yml"), `---
+starting_versions:
+- version: 1
+  ref: 'v200'
+  hotfixes:
+    "a":
+      depends_on:
+      - missing
+    "b":
+      depends_on:
+      - c
+    "c":
+      depends_on:
+      - b
+`)
+		})
+
+		It("reports unknown and circular dependencies", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			file := filepath.Join("1.9", "starting-versions.yml")
+			Expect(problems).To(Equal([]patcher.Problem{
+				{File: file, Message: `version 1 hotfix "a": depends on unknown hotfix "missing"`},
+				{File: file, Message: `version 1 hotfix "b": hotfix "b" has a circular depends_on`},
+				{File: file, Message: `version 1 hotfix "c": hotfix "c" has a circular depends_on`},
+			}))
+		})
+	})
+
+	Context("when a commit message template is invalid", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join(patchesRepo, "1.9", "starting-versions.yml"), `---
+commit_messages:
+  bump: "Bump {{.Path"
+starting_versions:
+- version: 1
+  ref: 'v200'
+`)
+		})
+
+		It("reports the template error", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].Message).To(HavePrefix("commit_messages: invalid bump commit message template: "))
+		})
+	})
+
+	Context("when the starting versions cannot be parsed", func() {
+		BeforeEach(func() {
+			writeFile(filepath.Join(patchesRepo, "1.9", "starting-versions.yml"), "%%%")
+		})
+
+		It("reports the parse error", func() {
+			problems, err := ps.Validate()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(problems).To(Equal([]patcher.Problem{
+				{File: filepath.Join("1.9", "starting-versions.yml"), Message: "could not find expected directive name"},
+			}))
+		})
+	})
+
+	Context("when there are no starting versions files", func() {
+		It("returns an error", func() {
+			emptyDir, err := ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(emptyDir)
+
+			_, err = patcher.NewPatchSet(emptyDir).Validate()
+			Expect(err).To(MatchError("no starting-versions.yml files found in " + emptyDir))
+		})
+	})
+})
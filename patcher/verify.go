@@ -0,0 +1,200 @@
+package patcher
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type Drift struct {
+	Path      string
+	Expected  string
+	Found     string
+	Submodule bool
+	Change    int
+	Changes   int
+}
+
+func (d Drift) String() string {
+	message := fmt.Sprintf("%s: expected %s, found %s", d.Path, describeObject(d.Expected), describeObject(d.Found))
+	if d.Change == 0 {
+		return fmt.Sprintf("%s, not made by any change", message)
+	}
+
+	return fmt.Sprintf("%s, introduced by change %d of %d", message, d.Change, d.Changes)
+}
+
+type TreeEntry struct {
+	Mode string
+	SHA  string
+}
+
+type verifier interface {
+	DiffTrees(expected, found string) ([]Drift, error)
+	SubmoduleTree(path, commit string) (map[string]TreeEntry, error)
+}
+
+type Verify struct {
+	repo    verifier
+	rebuilt verifier
+}
+
+func NewVerify(repo, rebuilt verifier) Verify {
+	return Verify{
+		repo:    repo,
+		rebuilt: rebuilt,
+	}
+}
+
+func (v Verify) Drift(checkpoint Checkpoint, rebuiltRef, branch string) ([]Drift, error) {
+	differences, err := v.rebuilt.DiffTrees(rebuiltRef, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []Drift
+	for _, drift := range differences {
+		if drift.Submodule && drift.Expected != "" && drift.Found != "" {
+			same, err := v.sameSubmoduleTree(drift.Path, drift.Expected, drift.Found)
+			if err != nil {
+				return nil, err
+			}
+
+			if same {
+				continue
+			}
+		}
+
+		drift.Change, err = changeFor(checkpoint.Changes, drift.Path)
+		if err != nil {
+			return nil, err
+		}
+		drift.Changes = len(checkpoint.Changes)
+
+		drifts = append(drifts, drift)
+	}
+
+	return drifts, nil
+}
+
+func (v Verify) sameSubmoduleTree(path, expectedCommit, foundCommit string) (bool, error) {
+	expected, err := v.rebuilt.SubmoduleTree(path, expectedCommit)
+	if err != nil {
+		return false, err
+	}
+
+	found, err := v.rebuilt.SubmoduleTree(path, foundCommit)
+	if err != nil {
+		found, err = v.repo.SubmoduleTree(path, foundCommit)
+		if err != nil {
+			return false, nil
+		}
+	}
+
+	if len(expected) != len(found) {
+		return false, nil
+	}
+
+	var names []string
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entry := expected[name]
+		other, ok := found[name]
+		if !ok || other.Mode != entry.Mode {
+			return false, nil
+		}
+
+		if other.SHA == entry.SHA {
+			continue
+		}
+
+		if entry.Mode != gitlinkMode {
+			return false, nil
+		}
+
+		same, err := v.sameSubmoduleTree(path+"/"+name, entry.SHA, other.SHA)
+		if err != nil || !same {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func changeFor(changes []Changeset, path string) (int, error) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+
+		if _, ok := change.Bumps[path]; ok {
+			return i + 1, nil
+		}
+
+		if _, ok := change.SubmodulePatches[path]; ok {
+			return i + 1, nil
+		}
+
+		if _, ok := change.SubmoduleAdditions[path]; ok {
+			return i + 1, nil
+		}
+
+		if _, ok := change.SubmoduleForks[path]; ok {
+			return i + 1, nil
+		}
+
+		for _, removal := range change.SubmoduleRemovals {
+			if removal == path {
+				return i + 1, nil
+			}
+		}
+
+		for _, patch := range change.Patches {
+			touched, err := patchTouches(patch, path)
+			if err != nil {
+				return 0, err
+			}
+
+			if touched {
+				return i + 1, nil
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+func patchTouches(patch, path string) (bool, error) {
+	file, err := os.Open(patch)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "diff --git ") {
+			continue
+		}
+
+		if strings.HasPrefix(line, fmt.Sprintf("diff --git a/%s b/", path)) || strings.HasSuffix(line, fmt.Sprintf(" b/%s", path)) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+func describeObject(sha string) string {
+	if sha == "" {
+		return "nothing"
+	}
+
+	return sha
+}
@@ -0,0 +1,178 @@
+package patcher_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/knit/patcher"
+	"github.com/pivotal-cf/knit/patcher/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+	var (
+		repo       *fakes.Verifier
+		rebuilt    *fakes.Verifier
+		patchesDir string
+		checkpoint patcher.Checkpoint
+	)
+
+	BeforeEach(func() {
+		repo = &fakes.Verifier{}
+		rebuilt = &fakes.Verifier{}
+
+		var err error
+		patchesDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(patchesDir, "first.patch"), []byte("Subject: first\n\ndiff --git a/config/app.yml b/config/app.yml\n--- a/config/app.yml\n+++ b/config/app.yml\n"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(patchesDir, "second.patch"), []byte("Subject: second\n\ndiff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		checkpoint = patcher.Checkpoint{
+			Changes: []patcher.Changeset{
+				{
+					Patches: []string{filepath.Join(patchesDir, "first.patch")},
+					Bumps:   map[string]string{"src/bumped": "bumped-sha"},
+				},
+				{
+					Patches:          []string{filepath.Join(patchesDir, "second.patch")},
+					SubmodulePatches: map[string][]string{"src/patched": []string{"some.patch"}},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(patchesDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("compares the rebuilt tree with the branch", func() {
+		drifts, err := patcher.NewVerify(repo, rebuilt).Drift(checkpoint, "HEAD", "branch-sha")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drifts).To(BeEmpty())
+
+		Expect(rebuilt.DiffTreesCall.Receives.Expected).To(Equal("HEAD"))
+		Expect(rebuilt.DiffTreesCall.Receives.Found).To(Equal("branch-sha"))
+	})
+
+	It("names the last change that touched every drifted path", func() {
+		rebuilt.DiffTreesCall.Returns.Drifts = []patcher.Drift{
+			{Path: "README.md", Expected: "readme-a", Found: "readme-b"},
+			{Path: "config/app.yml", Expected: "app-a", Found: "app-b"},
+			{Path: "src/bumped", Expected: "bumped-sha", Found: "other-sha", Submodule: true},
+			{Path: "unrelated.txt", Found: "unrelated"},
+		}
+		rebuilt.SubmoduleTreeCall.Returns.Trees = map[string]map[string]patcher.TreeEntry{
+			"bumped-sha": {"main.go": {Mode: "100644", SHA: "bumped-blob"}},
+			"other-sha":  {"main.go": {Mode: "100644", SHA: "other-blob"}},
+		}
+
+		drifts, err := patcher.NewVerify(repo, rebuilt).Drift(checkpoint, "HEAD", "branch-sha")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(drifts).To(Equal([]patcher.Drift{
+			{Path: "README.md", Expected: "readme-a", Found: "readme-b", Change: 2, Changes: 2},
+			{Path: "config/app.yml", Expected: "app-a", Found: "app-b", Change: 1, Changes: 2},
+			{Path: "src/bumped", Expected: "bumped-sha", Found: "other-sha", Submodule: true, Change: 1, Changes: 2},
+			{Path: "unrelated.txt", Found: "unrelated", Change: 0, Changes: 2},
+		}))
+
+		Expect(drifts[0].String()).To(Equal("README.md: expected readme-a, found readme-b, introduced by change 2 of 2"))
+		Expect(drifts[3].String()).To(Equal("unrelated.txt: expected nothing, found unrelated, not made by any change"))
+	})
+
+	Context("when a submodule commit differs but has the same tree", func() {
+		var patchedTree map[string]patcher.TreeEntry
+
+		BeforeEach(func() {
+			patchedTree = map[string]patcher.TreeEntry{
+				"main.go":   {Mode: "100644", SHA: "main-blob"},
+				"README.md": {Mode: "100644", SHA: "readme-blob"},
+			}
+
+			rebuilt.DiffTreesCall.Returns.Drifts = []patcher.Drift{
+				{Path: "src/patched", Expected: "rebuilt-sha", Found: "shipped-sha", Submodule: true},
+			}
+			rebuilt.SubmoduleTreeCall.Returns.Trees = map[string]map[string]patcher.TreeEntry{"rebuilt-sha": patchedTree}
+		})
+
+		It("does not report the submodule", func() {
+			repo.SubmoduleTreeCall.Returns.Trees = map[string]map[string]patcher.TreeEntry{"shipped-sha": patchedTree}
+
+			drifts, err := patcher.NewVerify(repo, rebuilt).Drift(checkpoint, "HEAD", "branch-sha")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(BeEmpty())
+
+			Expect(rebuilt.SubmoduleTreeCall.Receives.Commits).To(Equal([]string{"rebuilt-sha", "shipped-sha"}))
+			Expect(repo.SubmoduleTreeCall.Receives.Paths).To(Equal([]string{"src/patched"}))
+		})
+
+		Context("when a nested submodule was patched again", func() {
+			BeforeEach(func() {
+				rebuilt.SubmoduleTreeCall.Returns.Trees = map[string]map[string]patcher.TreeEntry{
+					"rebuilt-sha": {
+						"main.go":    {Mode: "100644", SHA: "main-blob"},
+						"src/nested": {Mode: "160000", SHA: "rebuilt-nested-sha"},
+					},
+					"rebuilt-nested-sha": {"nested.go": {Mode: "100644", SHA: "nested-blob"}},
+				}
+			})
+
+			It("does not report the submodule when the nested trees are the same", func() {
+				repo.SubmoduleTreeCall.Returns.Trees = map[string]map[string]patcher.TreeEntry{
+					"shipped-sha": {
+						"main.go":    {Mode: "100644", SHA: "main-blob"},
+						"src/nested": {Mode: "160000", SHA: "shipped-nested-sha"},
+					},
+					"shipped-nested-sha": {"nested.go": {Mode: "100644", SHA: "nested-blob"}},
+				}
+
+				drifts, err := patcher.NewVerify(repo, rebuilt).Drift(checkpoint, "HEAD", "branch-sha")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(drifts).To(BeEmpty())
+
+				Expect(rebuilt.SubmoduleTreeCall.Receives.Paths).To(ContainElement("src/patched/src/nested"))
+			})
+
+			It("reports the submodule when a nested tree differs", func() {
+				repo.SubmoduleTreeCall.Returns.Trees = map[string]map[string]patcher.TreeEntry{
+					"shipped-sha": {
+						"main.go":    {Mode: "100644", SHA: "main-blob"},
+						"src/nested": {Mode: "160000", SHA: "shipped-nested-sha"},
+					},
+					"shipped-nested-sha": {"nested.go": {Mode: "100644", SHA: "changed-blob"}},
+				}
+
+				drifts, err := patcher.NewVerify(repo, rebuilt).Drift(checkpoint, "HEAD", "branch-sha")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(drifts).To(HaveLen(1))
+				Expect(drifts[0].Path).To(Equal("src/patched"))
+			})
+		})
+
+		It("reports the submodule when the shipped commit cannot be found", func() {
+			drifts, err := patcher.NewVerify(repo, rebuilt).Drift(checkpoint, "HEAD", "branch-sha")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(Equal([]patcher.Drift{
+				{Path: "src/patched", Expected: "rebuilt-sha", Found: "shipped-sha", Submodule: true, Change: 2, Changes: 2},
+			}))
+		})
+	})
+
+	Context("when the trees cannot be compared", func() {
+		It("returns an error", func() {
+			rebuilt.DiffTreesCall.Returns.Error = errors.New("bad revision")
+
+			_, err := patcher.NewVerify(repo, rebuilt).Drift(checkpoint, "HEAD", "branch-sha")
+			Expect(err).To(MatchError("bad revision"))
+		})
+	})
+})
@@ -0,0 +1,229 @@
+package patcher
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type VersionRange struct {
+	Versions   string
+	Patches    []string
+	Submodules map[string]Submodule
+}
+
+type versionConstraint struct {
+	operator string
+	version  SemVer
+}
+
+var versionOperators = []string{">=", "<=", ">", "<", "="}
+
+func parseVersionConstraints(versions string) ([]versionConstraint, error) {
+	if strings.TrimSpace(versions) == "" {
+		return nil, fmt.Errorf("invalid range %q: expected constraints such as \">=3, <7\"", versions)
+	}
+
+	var constraints []versionConstraint
+	for _, part := range strings.Split(versions, ",") {
+		part = strings.TrimSpace(part)
+
+		operator := "="
+		for _, op := range versionOperators {
+			if strings.HasPrefix(part, op) {
+				operator = op
+				break
+			}
+		}
+
+		version, err := parseVersionID(strings.TrimSpace(strings.TrimPrefix(part, operator)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %s", versions, err)
+		}
+
+		constraints = append(constraints, versionConstraint{
+			operator: operator,
+			version:  version,
+		})
+	}
+
+	return constraints, nil
+}
+
+func (c versionConstraint) matches(version SemVer) bool {
+	comparison := version.Compare(c.version)
+
+	switch c.operator {
+	case ">=":
+		return comparison >= 0
+	case "<=":
+		return comparison <= 0
+	case ">":
+		return comparison > 0
+	case "<":
+		return comparison < 0
+	default:
+		return comparison == 0
+	}
+}
+
+func parseVersionID(id string) (SemVer, error) {
+	patch := id
+
+	var version SemVer
+	if i := strings.Index(id, "-"); i >= 0 {
+		patch = id[:i]
+		version.PreRelease = id[i+1:]
+
+		if err := validatePreRelease(version.PreRelease); err != nil {
+			return SemVer{}, fmt.Errorf("invalid version %q: pre-release %s", id, err)
+		}
+	}
+
+	if !numericIdentifierRegexp.MatchString(patch) {
+		return SemVer{}, fmt.Errorf("invalid version %q: expected a patch version such as 3 or 3-rc.1", id)
+	}
+
+	version.Patch, _ = strconv.Atoi(patch)
+
+	return version, nil
+}
+
+func versionChain(versions []StartingVersion, index int) ([]StartingVersion, error) {
+	version := versions[index]
+
+	if version.Extends != "" {
+		base, err := extendedVersion(versions, version)
+		if err != nil {
+			return nil, err
+		}
+
+		chain, err := versionChain(versions, base)
+		if err != nil {
+			return nil, err
+		}
+
+		if version.Ref == "" {
+			version.Ref = chain[len(chain)-1].Ref
+		}
+
+		return append(chain, version), nil
+	}
+
+	if version.Ref == "" {
+		return nil, fmt.Errorf("version %s: missing ref", version.id())
+	}
+
+	var chain []StartingVersion
+	for _, v := range versions {
+		if v.Ref == version.Ref && v.semVer().Compare(version.semVer()) <= 0 {
+			chain = append(chain, v)
+		}
+	}
+
+	sortStartingVersions(chain)
+
+	return chain, nil
+}
+
+func extendedVersion(versions []StartingVersion, version StartingVersion) (int, error) {
+	id, err := parseVersionID(version.Extends)
+	if err != nil {
+		return -1, fmt.Errorf("version %s: extends %s", version.id(), err)
+	}
+
+	if id.Compare(version.semVer()) >= 0 {
+		return -1, fmt.Errorf("version %s: extends %q, which is not an earlier version", version.id(), version.Extends)
+	}
+
+	base := -1
+	for i, v := range versions {
+		if v.semVer().Compare(id) == 0 {
+			base = i
+		}
+	}
+
+	if base < 0 {
+		return -1, fmt.Errorf("version %s: extends %q, which does not exist", version.id(), version.Extends)
+	}
+
+	return base, nil
+}
+
+func applyRanges(chain []StartingVersion, ranges []VersionRange, target SemVer) ([]StartingVersion, error) {
+	for _, r := range ranges {
+		constraints, err := parseVersionConstraints(r.Versions)
+		if err != nil {
+			return nil, err
+		}
+
+		if !inRange(constraints, target) {
+			continue
+		}
+
+		index := len(chain) - 1
+		for i, v := range chain {
+			if inRange(constraints, v.semVer()) {
+				index = i
+				break
+			}
+		}
+
+		chain[index] = chain[index].withRange(r)
+	}
+
+	return chain, nil
+}
+
+func inRange(constraints []versionConstraint, version SemVer) bool {
+	for _, constraint := range constraints {
+		if !constraint.matches(version) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (v StartingVersion) withRange(r VersionRange) StartingVersion {
+	v.Patches = append(append([]string{}, v.Patches...), r.Patches...)
+
+	submodules := map[string]Submodule{}
+	for path, submodule := range v.Submodules {
+		submodules[path] = submodule
+	}
+
+	for path, rangeSubmodule := range r.Submodules {
+		submodule, ok := submodules[path]
+		if !ok {
+			submodules[path] = rangeSubmodule
+			continue
+		}
+
+		if submodule.Ref == "" {
+			submodule.Ref = rangeSubmodule.Ref
+		}
+
+		if submodule.Add.URL == "" {
+			submodule.Add = rangeSubmodule.Add
+		}
+
+		if submodule.Fork == "" {
+			submodule.Fork = rangeSubmodule.Fork
+		}
+
+		submodule.Remove = submodule.Remove || rangeSubmodule.Remove
+		submodule.Patches = append(append([]string{}, submodule.Patches...), rangeSubmodule.Patches...)
+		submodules[path] = submodule
+	}
+	v.Submodules = submodules
+
+	return v
+}
+
+func sortStartingVersions(versions []StartingVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].semVer().Compare(versions[j].semVer()) < 0
+	})
+}
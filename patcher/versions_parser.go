@@ -3,17 +3,19 @@ package patcher
 import "fmt"
 
 type Checkpoint struct {
-	Changes     []Changeset
-	CheckoutRef string
-	FinalBranch string
+	Changes        []Changeset    `json:"changes"`
+	CheckoutRef    string         `json:"checkout_ref"`
+	FinalBranch    string         `json:"final_branch"`
+	CommitMessages CommitMessages `json:"commit_messages"`
 }
 
 type Changeset struct {
-	Patches            []string
-	Bumps              map[string]string
-	SubmodulePatches   map[string][]string
-	SubmoduleAdditions map[string]SubmoduleAddition
-	SubmoduleRemovals  []string
+	Patches            []string                     `json:"patches"`
+	Bumps              map[string]string            `json:"bumps"`
+	SubmodulePatches   map[string][]string          `json:"submodule_patches"`
+	SubmoduleAdditions map[string]SubmoduleAddition `json:"submodule_additions"`
+	SubmoduleRemovals  []string                     `json:"submodule_removals"`
+	SubmoduleForks     map[string]string            `json:"submodule_forks,omitempty"`
 }
 
 type patchSet interface {
@@ -51,11 +53,13 @@ func (p VersionsParser) GetCheckpoint() (Checkpoint, error) {
 			SubmodulePatches:   version.SubmodulePatches,
 			SubmoduleAdditions: version.SubmoduleAdditions,
 			SubmoduleRemovals:  version.SubmoduleRemovals,
+			SubmoduleForks:     version.SubmoduleForks,
 		})
 	}
 
-	checkpoint.CheckoutRef = versionsToApply[0].Ref
+	checkpoint.CheckoutRef = versionsToApply[len(versionsToApply)-1].Ref
 	checkpoint.FinalBranch = p.version
+	checkpoint.CommitMessages = versionsToApply[len(versionsToApply)-1].CommitMessages
 
 	return checkpoint, nil
 }
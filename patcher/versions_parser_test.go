@@ -1,6 +1,7 @@
 package patcher_test
 
 import (
+	"encoding/json"
 	"errors"
 
 	"github.com/pivotal-cf/knit/patcher"
@@ -22,6 +23,19 @@ var _ = Describe("VersionsParser", func() {
 			vp = patcher.NewVersionsParser("1.9.2", patchSet)
 		})
 
+		It("checks out the ref of the requested version", func() {
+			patchSet.VersionsToApplyForCall.Returns.Versions = []patcher.Version{
+				{Major: 1, Minor: 9, Patch: 1, Ref: "v123"},
+				{Major: 1, Minor: 9, Patch: 2, Ref: "v124"},
+			}
+
+			checkpoint, err := vp.GetCheckpoint()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(checkpoint.CheckoutRef).To(Equal("v124"))
+			Expect(checkpoint.Changes).To(HaveLen(2))
+		})
+
 		It("returns the checkpoint of the patches repository", func() {
 			patchSet.VersionsToApplyForCall.Returns.Versions = []patcher.Version{
 				{
@@ -93,6 +107,37 @@ var _ = Describe("VersionsParser", func() {
 			Expect(patchSet.VersionsToApplyForCall.Receives.Version).To(Equal("1.9.2"))
 		})
 
+		It("encodes the checkpoint as stable JSON", func() {
+			patchSet.VersionsToApplyForCall.Returns.Versions = []patcher.Version{
+				{
+					Ref:     "v124",
+					Patches: []string{"patch-1"},
+					SubmoduleBumps: map[string]string{
+						"src/foo": "ref-1",
+						"src/bar": "ref-2",
+					},
+					SubmodulePatches: map[string][]string{
+						"src/foo": {"foo-1.patch"},
+					},
+					SubmoduleAdditions: map[string]patcher.SubmoduleAddition{
+						"src/baz": patcher.SubmoduleAddition{
+							URL: "fake-url",
+							Ref: "fake-ref",
+						},
+					},
+					SubmoduleRemovals: []string{"some/fake/path"},
+				},
+			}
+
+			checkpoint, err := vp.GetCheckpoint()
+			Expect(err).NotTo(HaveOccurred())
+
+			output, err := json.Marshal(checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(output)).To(Equal(`{"changes":[{"patches":["patch-1"],"bumps":{"src/bar":"ref-2","src/foo":"ref-1"},"submodule_patches":{"src/foo":["foo-1.patch"]},"submodule_additions":{"src/baz":{"url":"fake-url","ref":"fake-ref","branch":""}},"submodule_removals":["some/fake/path"]}],"checkout_ref":"v124","final_branch":"1.9.2","commit_messages":{}}`))
+		})
+
 		Context("when the version includes a hotfix", func() {
 			BeforeEach(func() {
 				vp = patcher.NewVersionsParser("3.2.1+something.else", patchSet)
@@ -0,0 +1,97 @@
+package patcher
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	yamlLineRegexp     = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	unknownFieldRegexp = regexp.MustCompile(`^field (\S+) not found in type (\S+)$`)
+	duplicateKeyRegexp = regexp.MustCompile(`^field (\S+) already set in type (\S+)$`)
+)
+
+var schemaNames = map[string]string{
+	"patcher.StartingVersions":  "starting versions",
+	"patcher.StartingVersion":   "version",
+	"patcher.Submodule":         "submodule",
+	"patcher.SubmoduleAddition": "submodule addition",
+	"patcher.Hotfix":            "hotfix",
+	"patcher.CommitMessages":    "commit messages",
+	"patcher.VersionRange":      "range",
+	"patcher.manifest":          "manifest",
+}
+
+type YAMLError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e YAMLError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+type YAMLErrors []YAMLError
+
+func (e YAMLErrors) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func newYAMLErrors(file string, err error) YAMLErrors {
+	messages := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		messages = typeErr.Errors
+	}
+
+	var errs YAMLErrors
+	for _, message := range messages {
+		errs = append(errs, newYAMLError(file, message))
+	}
+
+	return errs
+}
+
+func newYAMLError(file, message string) YAMLError {
+	yamlErr := YAMLError{
+		File:    file,
+		Message: strings.TrimPrefix(message, "yaml: "),
+	}
+
+	matches := yamlLineRegexp.FindStringSubmatch(message)
+	if len(matches) == 3 {
+		yamlErr.Line, _ = strconv.Atoi(matches[1])
+		yamlErr.Message = matches[2]
+	}
+
+	if matches := unknownFieldRegexp.FindStringSubmatch(yamlErr.Message); len(matches) == 3 {
+		yamlErr.Message = fmt.Sprintf("unknown key %q in %s", matches[1], schemaName(matches[2]))
+	}
+
+	if matches := duplicateKeyRegexp.FindStringSubmatch(yamlErr.Message); len(matches) == 3 {
+		yamlErr.Message = fmt.Sprintf("duplicate key %q in %s", matches[1], schemaName(matches[2]))
+	}
+
+	return yamlErr
+}
+
+func schemaName(typeName string) string {
+	if name, ok := schemaNames[typeName]; ok {
+		return name
+	}
+
+	return typeName
+}
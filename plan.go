@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pivotal-cf/knit/patcher"
+)
+
+func plan(args []string) {
+	var (
+		patchesRepository string
+		patchesRef        string
+		patchManifest     string
+		version           string
+		format            string
+	)
+
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	flags.StringVar(&patchesRepository, "patch-repository", "", "")
+	flags.StringVar(&patchesRef, "patch-repository-ref", "", "")
+	flags.StringVar(&patchManifest, "patch-manifest", "", "")
+	flags.StringVar(&version, "version", "", "")
+	flags.StringVar(&format, "format", "json", "")
+	flags.Parse(args)
+
+	var missingFlag string
+	switch {
+	case patchesRepository != "" && patchManifest != "":
+		missingFlag = "patch-repository and patch-manifest cannot be used together"
+	case patchesRef != "" && patchesRepository == "":
+		missingFlag = "patch-repository-ref requires patch-repository"
+	case patchesRepository == "" && patchManifest == "":
+		missingFlag = "patch-repository is a required flag"
+	case version == "":
+		missingFlag = "version is a required flag"
+	}
+
+	if missingFlag != "" {
+		fatal(missingFlag)
+	}
+
+	runner, err := patcher.NewCommandRunner("git", true)
+	if err != nil {
+		fatal(err)
+	}
+
+	patchSet, _, err := newPatchSet(runner, patchesRepository, patchesRef, patchManifest, true)
+	if err != nil {
+		fatal(err)
+	}
+
+	checkpoint, err := patcher.NewVersionsParser(version, patchSet).GetCheckpoint()
+	if err != nil {
+		fatal(err)
+	}
+
+	switch format {
+	case "json":
+		output, err := json.MarshalIndent(checkpoint, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Fprintf(os.Stdout, "%s\n", output)
+	case "text":
+		err = patcher.NewApply(patcher.NewDryRun(os.Stdout), nil).Checkpoint(checkpoint)
+		if err != nil {
+			fatal(err)
+		}
+	default:
+		fatalf("unknown format %q, expected json or text", format)
+	}
+}
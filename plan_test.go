@@ -0,0 +1,188 @@
+package main_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plan", func() {
+	var patchesDir string
+
+	BeforeEach(func() {
+		var err error
+		patchesDir, err = ioutil.TempDir("", "patch-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(patchesDir, "1.2"), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(patchesDir, "1.2", "starting-versions.yml"), []byte(`---
+starting_versions:
+- version: 1
+  ref: v1
+  patches:
+  - change.patch
+- version: 2
+  ref: v1
+  submodules:
+    "src/some-sub":
+      ref: some-sha`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(patchesDir)
+	})
+
+	It("prints the checkpoint as JSON", func() {
+		command := exec.Command(pathToKnit, "plan",
+			"-patch-repository", patchesDir,
+			"-version", "1.2.2",
+			"-format", "json")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "1m").Should(gexec.Exit(0))
+
+		var plan struct {
+			CheckoutRef string `json:"checkout_ref"`
+			FinalBranch string `json:"final_branch"`
+			Changes     []struct {
+				Patches []string          `json:"patches"`
+				Bumps   map[string]string `json:"bumps"`
+			} `json:"changes"`
+		}
+		err = json.Unmarshal(session.Out.Contents(), &plan)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(plan.CheckoutRef).To(Equal("v1"))
+		Expect(plan.FinalBranch).To(Equal("1.2.2"))
+		Expect(plan.Changes).To(HaveLen(2))
+		Expect(plan.Changes[0].Patches).To(Equal([]string{filepath.Join(patchesDir, "1.2", "change.patch")}))
+		Expect(plan.Changes[1].Bumps).To(Equal(map[string]string{"src/some-sub": "some-sha"}))
+	})
+
+	It("lists the removed submodules in the order of their paths", func() {
+		err := ioutil.WriteFile(filepath.Join(patchesDir, "1.2", "starting-versions.yml"), []byte(`---
+starting_versions:
+- version: 1
+  ref: v1
+  submodules:
+    "src/c":
+      remove: true
+    "src/a":
+      remove: true
+    "src/d":
+      remove: true
+    "src/b":
+      remove: true`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		for i := 0; i < 3; i++ {
+			command := exec.Command(pathToKnit, "plan",
+				"-patch-repository", patchesDir,
+				"-version", "1.2.1",
+				"-format", "json")
+			session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session, "1m").Should(gexec.Exit(0))
+
+			var plan struct {
+				Changes []struct {
+					SubmoduleRemovals []string `json:"submodule_removals"`
+				} `json:"changes"`
+			}
+			err = json.Unmarshal(session.Out.Contents(), &plan)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(plan.Changes).To(HaveLen(1))
+			Expect(plan.Changes[0].SubmoduleRemovals).To(Equal([]string{"src/a", "src/b", "src/c", "src/d"}))
+		}
+	})
+
+	It("prints the checkpoint as text", func() {
+		command := exec.Command(pathToKnit, "plan",
+			"-patch-repository", patchesDir,
+			"-version", "1.2.2",
+			"-format", "text")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "1m").Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say("checkout v1"))
+		Expect(session.Out).To(gbytes.Say("bump submodule src/some-sub to some-sha"))
+	})
+
+	It("names the patches read from a ref after the ref", func() {
+		err := ioutil.WriteFile(filepath.Join(patchesDir, "1.2", "change.patch"), []byte("a patch"), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, args := range [][]string{
+			{"init"},
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "patches"},
+			{"tag", "pinned"},
+		} {
+			command := exec.Command("git", args...)
+			command.Dir = patchesDir
+			output, err := command.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(output))
+		}
+
+		command := exec.Command(pathToKnit, "plan",
+			"-patch-repository", patchesDir,
+			"-patch-repository-ref", "pinned",
+			"-version", "1.2.2",
+			"-format", "text")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "1m").Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say("apply patch pinned:1.2/change.patch"))
+	})
+
+	It("rejects unknown formats", func() {
+		command := exec.Command(pathToKnit, "plan",
+			"-patch-repository", patchesDir,
+			"-version", "1.2.2",
+			"-format", "xml")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "1m").Should(gexec.Exit(1))
+
+		Expect(session.Err).To(gbytes.Say(`unknown format "xml"`))
+	})
+
+	It("reads the patches from a manifest", func() {
+		manifest := filepath.Join(patchesDir, "manifest.yml")
+		err := ioutil.WriteFile(manifest, []byte(`---
+releases:
+  "1.2":
+    starting_versions:
+    - version: 1
+      ref: v1
+      patches:
+      - 1.2/change.patch
+`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		command := exec.Command(pathToKnit, "plan",
+			"-patch-manifest", manifest,
+			"-version", "1.2.1",
+			"-format", "text")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "1m").Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say("checkout v1"))
+		Expect(session.Out).To(gbytes.Say(filepath.Join(patchesDir, "1.2", "change.patch")))
+	})
+})
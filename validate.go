@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pivotal-cf/knit/patcher"
+)
+
+func validate(args []string) {
+	var (
+		patchesRepository string
+		patchesRef        string
+		patchManifest     string
+	)
+
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.StringVar(&patchesRepository, "patch-repository", "", "")
+	flags.StringVar(&patchesRef, "patch-repository-ref", "", "")
+	flags.StringVar(&patchManifest, "patch-manifest", "", "")
+	flags.Parse(args)
+
+	var missingFlag string
+	switch {
+	case patchesRepository != "" && patchManifest != "":
+		missingFlag = "patch-repository and patch-manifest cannot be used together"
+	case patchesRef != "" && patchesRepository == "":
+		missingFlag = "patch-repository-ref requires patch-repository"
+	case patchesRepository == "" && patchManifest == "":
+		missingFlag = "patch-repository is a required flag"
+	}
+
+	if missingFlag != "" {
+		fatal(missingFlag)
+	}
+
+	source := patchesRepository
+	switch {
+	case patchManifest != "":
+		source = patchManifest
+	case patchesRef != "":
+		source = fmt.Sprintf("%s at %s", patchesRepository, patchesRef)
+	}
+
+	runner, err := patcher.NewCommandRunner("git", true)
+	if err != nil {
+		fatal(err)
+	}
+
+	patchSet, _, err := newPatchSet(runner, patchesRepository, patchesRef, patchManifest, false)
+	if err != nil {
+		fatal(err)
+	}
+
+	problems, err := patchSet.Validate()
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stdout, problem)
+	}
+
+	if len(problems) > 0 {
+		fatalf("found %d problem(s) in %s", len(problems), source)
+	}
+
+	fmt.Printf("%s is valid\n", source)
+}
@@ -0,0 +1,110 @@
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	var patchesDir string
+
+	BeforeEach(func() {
+		var err error
+		patchesDir, err = ioutil.TempDir("", "patch-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(patchesDir, "1.2"), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(patchesDir, "1.2", "change.patch"), []byte{}, 0644)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(patchesDir)
+	})
+
+	It("succeeds for a valid patch repository", func() {
+		err := ioutil.WriteFile(filepath.Join(patchesDir, "1.2", "starting-versions.yml"), []byte(`---
+starting_versions:
+- version: 1
+  ref: master
+  patches:
+  - change.patch`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		command := exec.Command(pathToKnit, "validate", "-patch-repository", patchesDir)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session, "1m").Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("is valid"))
+	})
+
+	It("reports the problems it finds", func() {
+		err := ioutil.WriteFile(filepath.Join(patchesDir, "1.2", "starting-versions.yml"), []byte(`---
+starting_versions:
+- version: 1
+  ref: master
+  patches:
+  - missing.patch`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		command := exec.Command(pathToKnit, "validate", "-patch-repository", patchesDir)
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session, "1m").Should(gexec.Exit(1))
+		Expect(session.Out).To(gbytes.Say(`version 1: missing patch file "missing.patch"`))
+		Expect(session.Err).To(gbytes.Say(`found 1 problem\(s\)`))
+	})
+
+	It("validates the patch repository at a ref instead of the work tree", func() {
+		err := ioutil.WriteFile(filepath.Join(patchesDir, "1.2", "starting-versions.yml"), []byte(`---
+starting_versions:
+- version: 1
+  ref: master
+  patches:
+  - change.patch`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, args := range [][]string{
+			{"init"},
+			{"add", "-A"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "patches"},
+			{"tag", "pinned"},
+		} {
+			command := exec.Command("git", args...)
+			command.Dir = patchesDir
+			output, err := command.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(output))
+		}
+
+		err = os.Remove(filepath.Join(patchesDir, "1.2", "change.patch"))
+		Expect(err).NotTo(HaveOccurred())
+
+		command := exec.Command(pathToKnit, "validate", "-patch-repository", patchesDir, "-patch-repository-ref", "pinned")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session, "1m").Should(gexec.Exit(0))
+		Expect(session.Out).To(gbytes.Say("at pinned is valid"))
+	})
+
+	It("requires the patch repository", func() {
+		command := exec.Command(pathToKnit, "validate")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(session, "1m").Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("patch-repository is a required flag"))
+	})
+})
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/pivotal-cf/knit/patcher"
+)
+
+func verify(args []string) {
+	var (
+		releaseRepository string
+		patchesRepository string
+		patchesRef        string
+		patchManifest     string
+		version           string
+		branch            string
+		committerName     string
+		committerEmail    string
+		quiet             bool
+		reproducible      bool
+	)
+
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.StringVar(&releaseRepository, "repository-to-patch", "", "")
+	flags.StringVar(&patchesRepository, "patch-repository", "", "")
+	flags.StringVar(&patchesRef, "patch-repository-ref", "", "")
+	flags.StringVar(&patchManifest, "patch-manifest", "", "")
+	flags.StringVar(&version, "version", "", "")
+	flags.StringVar(&branch, "branch", "", "")
+	flags.StringVar(&committerName, "committer-name", "", "")
+	flags.StringVar(&committerEmail, "committer-email", "", "")
+	flags.BoolVar(&quiet, "quiet", false, "")
+	flags.BoolVar(&reproducible, "reproducible", false, "")
+	flags.Parse(args)
+
+	var missingFlag string
+	switch {
+	case releaseRepository == "":
+		missingFlag = "repository-to-patch is a required flag"
+	case patchesRepository != "" && patchManifest != "":
+		missingFlag = "patch-repository and patch-manifest cannot be used together"
+	case patchesRef != "" && patchesRepository == "":
+		missingFlag = "patch-repository-ref requires patch-repository"
+	case patchesRepository == "" && patchManifest == "":
+		missingFlag = "patch-repository is a required flag"
+	case version == "":
+		missingFlag = "version is a required flag"
+	}
+
+	if missingFlag != "" {
+		fatal(missingFlag)
+	}
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		fatal(err)
+	}
+
+	runner, err := patcher.NewCommandRunner(gitPath, quiet)
+	if err != nil {
+		fatal(err)
+	}
+
+	err = checkGitVersion(runner)
+	if err != nil {
+		fatal(err)
+	}
+
+	committerName, err = committerIdentity(runner, releaseRepository, committerName, "GIT_COMMITTER_NAME", "user.name")
+	if err != nil {
+		fatal(err)
+	}
+
+	committerEmail, err = committerIdentity(runner, releaseRepository, committerEmail, "GIT_COMMITTER_EMAIL", "user.email")
+	if err != nil {
+		fatal(err)
+	}
+
+	var commitDate string
+	if reproducible {
+		commitDate, err = sourceDateEpoch()
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	patchSet, _, err := newPatchSet(runner, patchesRepository, patchesRef, patchManifest, false)
+	if err != nil {
+		fatal(err)
+	}
+
+	checkpoint, err := patcher.NewVersionsParser(version, patchSet).GetCheckpoint()
+	if err != nil {
+		fatal(err)
+	}
+
+	if branch == "" {
+		branch = checkpoint.FinalBranch
+	}
+
+	newRepo := func(path string, messages patcher.CommitMessages) patcher.Repo {
+		repo := patcher.NewRepo(runner, path, committerName, committerEmail)
+		repo.CommitMessages = messages
+		repo.Reproducible = reproducible
+		repo.CommitDate = commitDate
+		return repo
+	}
+
+	repo := newRepo(releaseRepository, patcher.CommitMessages{})
+
+	branchCommit, err := repo.ResolveCommit(branch)
+	if err != nil {
+		fatal(err)
+	}
+
+	var drifts []patcher.Drift
+	err = buildInWorktree(repo, newRepo, checkpoint, "knit-verify", func(worktree string, worktreeRepo patcher.Repo) error {
+		drifts, err = patcher.NewVerify(repo, worktreeRepo).Drift(checkpoint, "HEAD", branchCommit)
+		return err
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, drift := range drifts {
+		fmt.Fprintln(os.Stdout, drift)
+	}
+
+	if len(drifts) > 0 {
+		fatalf("%s has drifted from %s in %d place(s)", branch, version, len(drifts))
+	}
+
+	fmt.Printf("%s matches %s\n", branch, version)
+}
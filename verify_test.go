@@ -0,0 +1,121 @@
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+	var (
+		repoToPatch string
+		patchesDir  string
+	)
+
+	git := func(args ...string) string {
+		command := exec.Command("git", args...)
+		command.Dir = repoToPatch
+		output, err := command.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("Error: %s", output))
+
+		return strings.TrimSpace(string(output))
+	}
+
+	verify := func() *gexec.Session {
+		command := exec.Command(pathToKnit, "verify",
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-version", "1.2.1",
+			"-branch", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		return session
+	}
+
+	BeforeEach(func() {
+		var err error
+		patchesDir, err = ioutil.TempDir("", "patch-dir")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(patchesDir, "1.2"), os.ModePerm)
+		Expect(err).NotTo(HaveOccurred())
+
+		repoToPatch, err = ioutil.TempDir("", "repo-to-patch")
+		Expect(err).NotTo(HaveOccurred())
+
+		initGitRepo(repoToPatch)
+
+		createPatch(repoToPatch, patchesDir)
+
+		command := exec.Command(pathToKnit,
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-version", "1.2.1")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "10m").Should(gexec.Exit(0))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(repoToPatch)
+		os.RemoveAll(patchesDir)
+	})
+
+	It("reports that a branch built from the patches matches them", func() {
+		session := verify()
+		Eventually(session, "10m").Should(gexec.Exit(0))
+
+		Expect(session.Out).To(gbytes.Say("1.2.1 matches 1.2.1"))
+		Expect(git("branch", "--list", "knit-verify-*")).To(BeEmpty())
+	})
+
+	It("rebuilds with the reproducible settings", func() {
+		command := exec.Command(pathToKnit, "verify",
+			"-repository-to-patch", repoToPatch,
+			"-patch-repository", patchesDir,
+			"-version", "1.2.1",
+			"-reproducible")
+		command.Env = append(os.Environ(), "SOURCE_DATE_EPOCH=yesterday")
+		session, err := gexec.Start(command, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, "1m").Should(gexec.Exit(1))
+
+		Expect(session.Err).To(gbytes.Say(`SOURCE_DATE_EPOCH must be a number of seconds since the epoch, got "yesterday"`))
+	})
+
+	Context("when the branch has drifted", func() {
+		BeforeEach(func() {
+			err := ioutil.WriteFile(filepath.Join(repoToPatch, "file-in-repo.txt"), []byte("hello, drift!"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = ioutil.WriteFile(filepath.Join(repoToPatch, "extra.txt"), []byte("extra"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			git("add", "-A")
+			git("commit", "-m", "an unreviewed change")
+		})
+
+		It("reports every drifted path and the change that should have made it", func() {
+			expected := git("rev-parse", "1.2.1^:file-in-repo.txt")
+			found := git("rev-parse", "1.2.1:file-in-repo.txt")
+			extra := git("rev-parse", "1.2.1:extra.txt")
+
+			session := verify()
+			Eventually(session, "10m").Should(gexec.Exit(1))
+
+			Expect(session.Out).To(gbytes.Say(fmt.Sprintf("extra.txt: expected nothing, found %s, not made by any change", extra)))
+			Expect(session.Out).To(gbytes.Say(fmt.Sprintf("file-in-repo.txt: expected %s, found %s, introduced by change 1 of 1", expected, found)))
+			Expect(session.Err).To(gbytes.Say(`1.2.1 has drifted from 1.2.1 in 2 place\(s\)`))
+		})
+	})
+})